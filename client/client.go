@@ -2,6 +2,7 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"io"
 	"io/ioutil"
@@ -26,11 +27,34 @@ type Client struct {
 	History    []History
 }
 
+// ResponseError is returned by MakeRequest for every response whose status code is not 200 OK. The body is kept, so
+// callers can still decode SOAP faults sent along with a 500.
+type ResponseError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e *ResponseError) Error() string {
+	return string(e.Body)
+}
+
 type HTTPClientDoer interface {
 	Do(req *http.Request) (*http.Response, error)
 }
 
 func (c *Client) MakeRequest(requestMethod, requestURL string, requestBody io.Reader, decodedResponse interface{}) error {
+	return c.MakeRequestWithHeader(requestMethod, requestURL, nil, requestBody, decodedResponse)
+}
+
+// MakeRequestWithHeader : Like MakeRequest, but sets every value of requestHeader on top of the client's header, for
+// example the Content-Type of a SOAP request
+func (c *Client) MakeRequestWithHeader(requestMethod, requestURL string, requestHeader http.Header, requestBody io.Reader, decodedResponse interface{}) error {
+	return c.MakeRequestContext(context.Background(), requestMethod, requestURL, requestHeader, requestBody, decodedResponse)
+}
+
+// MakeRequestContext : Like MakeRequestWithHeader, but the request is bound to ctx. Cancelling ctx aborts the request
+// as well as decoding the response.
+func (c *Client) MakeRequestContext(ctx context.Context, requestMethod, requestURL string, requestHeader http.Header, requestBody io.Reader, decodedResponse interface{}) error {
 	val := reflect.ValueOf(decodedResponse)
 	if val.Kind() != reflect.Ptr {
 		return errors.New("non-pointer decodedResponse passed to MakeRequest")
@@ -48,15 +72,17 @@ func (c *Client) MakeRequest(requestMethod, requestURL string, requestBody io.Re
 		requestBody = ioutil.NopCloser(bytes.NewBuffer(buf))
 	}
 
-	req, err := http.NewRequest(requestMethod, requestURL, requestBody)
+	req, err := http.NewRequestWithContext(ctx, requestMethod, requestURL, requestBody)
 	if err != nil {
 		return err
 	}
 
 	if c.Header != nil {
-		req.Header = *c.Header
+		req.Header = c.Header.Clone()
+	}
+	for key, values := range requestHeader {
+		req.Header[key] = values
 	}
-	req.Header.Set("Content-Type", "application/soap+xml")
 
 	if c.UseHistory {
 		hs.Request = req
@@ -82,12 +108,12 @@ func (c *Client) MakeRequest(requestMethod, requestURL string, requestBody io.Re
 			return err
 		}
 
-		err = errors.New(string(b))
+		err = &ResponseError{StatusCode: resp.StatusCode, Body: b}
 		return err
 	}
 
 	var responseBody io.Reader
-	responseBody = resp.Body
+	responseBody = &contextReader{ctx: ctx, r: resp.Body}
 	if c.UseHistory {
 		hs.Response = resp
 		hs.ResponseBody = &bytes.Buffer{}
@@ -101,3 +127,22 @@ func (c *Client) MakeRequest(requestMethod, requestURL string, requestBody io.Re
 
 	return nil
 }
+
+// NewContextReader : Returns a reader of r which fails every read once ctx is done, which aborts decoding what is read
+func NewContextReader(ctx context.Context, r io.Reader) io.Reader {
+	return &contextReader{ctx: ctx, r: r}
+}
+
+// contextReader fails every read once its context is done
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return r.r.Read(p)
+}
@@ -0,0 +1,269 @@
+package goat
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/sezzle/goat/wsdl"
+	"github.com/sezzle/sezzle-go-xml"
+)
+
+// Fault is the error returned for a SOAP 1.1 or SOAP 1.2 fault, no matter if it was sent with a 500 or a 200 status
+type Fault struct {
+	StatusCode int // HTTP status code of the response carrying the fault
+
+	Code     string   // faultcode (SOAP 1.1) or Code/Value (SOAP 1.2), e.g. "soap:Client"
+	Subcodes []string // Code/Subcode/Value chain, outermost first (SOAP 1.2 only)
+	Reason   string   // faultstring (SOAP 1.1) or the first Reason/Text (SOAP 1.2)
+	Actor    string   // faultactor (SOAP 1.1 only)
+	Node     string   // Node (SOAP 1.2 only)
+	Role     string   // Role (SOAP 1.2 only)
+
+	Name    string   // name of the WSDL fault declared on the operation whose message matches the detail, if any
+	Element xml.Name // first element inside the detail
+	Detail  []byte   // inner XML of detail (SOAP 1.1) or Detail (SOAP 1.2)
+}
+
+func (f *Fault) Error() string {
+	if f.Name != "" {
+		return fmt.Sprintf("soap fault %s (%s): %s", f.Name, f.Code, f.Reason)
+	}
+
+	return fmt.Sprintf("soap fault (%s): %s", f.Code, f.Reason)
+}
+
+// LocalCode : Returns the fault code without its namespace prefix, e.g. "Client" for "soap:Client"
+func (f *Fault) LocalCode() string {
+	return localName(f.Code)
+}
+
+// DecodeDetail : Unmarshals the first element of the fault detail into v
+func (f *Fault) DecodeDetail(v interface{}) error {
+	if len(f.Detail) == 0 {
+		return fmt.Errorf("fault has no detail")
+	}
+
+	return xml.Unmarshal(f.Detail, v)
+}
+
+type faultSubcode struct {
+	Value   string        `xml:"Value"`
+	Subcode *faultSubcode `xml:"Subcode"`
+}
+
+type faultDetail struct {
+	Data []byte `xml:",innerxml"`
+}
+
+// rawFault can hold both, SOAP 1.1 and SOAP 1.2 faults, since their element names do not overlap
+type rawFault struct {
+	XMLName xml.Name `xml:"Fault"`
+
+	FaultCode   string      `xml:"faultcode"`
+	FaultString string      `xml:"faultstring"`
+	FaultActor  string      `xml:"faultactor"`
+	FaultDetail faultDetail `xml:"detail"`
+
+	Code struct {
+		Value   string        `xml:"Value"`
+		Subcode *faultSubcode `xml:"Subcode"`
+	} `xml:"Code"`
+	Reason struct {
+		Text []string `xml:"Text"`
+	} `xml:"Reason"`
+	Node   string      `xml:"Node"`
+	Role   string      `xml:"Role"`
+	Detail faultDetail `xml:"Detail"`
+}
+
+// parseFault returns the fault contained in the SOAP body data, or nil if the body does not start with a Fault of
+// SOAP 1.1 or SOAP 1.2. namespaces are the declarations in scope of the body, which resolve the prefixes of the Fault
+// and the detail element.
+func parseFault(body []byte, namespaces []xml.Attr) (*Fault, error) {
+	start, err := firstElement(body, namespaces)
+	if err != nil || start.Name.Local != "Fault" {
+		return nil, nil
+	}
+
+	// A response element of the service may be called Fault as well
+	if start.Name.Space != wsdl.Soap11.EnvelopeNamespace && start.Name.Space != wsdl.Soap12.EnvelopeNamespace {
+		return nil, nil
+	}
+
+	raw := new(rawFault)
+	err = xml.Unmarshal(body, raw)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &Fault{
+		Code:   raw.FaultCode,
+		Reason: raw.FaultString,
+		Actor:  raw.FaultActor,
+		Node:   raw.Node,
+		Role:   raw.Role,
+		Detail: bytes.TrimSpace(raw.FaultDetail.Data),
+	}
+
+	if raw.Code.Value != "" {
+		f.Code = raw.Code.Value
+		for s := raw.Code.Subcode; s != nil; s = s.Subcode {
+			f.Subcodes = append(f.Subcodes, s.Value)
+		}
+	}
+
+	if len(raw.Reason.Text) > 0 {
+		f.Reason = raw.Reason.Text[0]
+	}
+
+	if len(raw.Detail.Data) > 0 {
+		f.Detail = bytes.TrimSpace(raw.Detail.Data)
+	}
+
+	if len(f.Detail) > 0 {
+		var name xml.Name
+		name, err = detailElement(body, namespaces)
+		if err == nil {
+			f.Element = name
+		}
+	}
+
+	return f, nil
+}
+
+// scopeBody returns body within an element declaring namespaces, the declarations of the envelope and the body element,
+// so that a decoder resolves the prefixes declared there as well
+func scopeBody(body []byte, namespaces []xml.Attr) (*bytes.Buffer, error) {
+	var b bytes.Buffer
+	b.WriteString("<scope")
+	for _, attr := range namespaces {
+		switch {
+		case attr.Name.Space == "xmlns":
+			b.WriteString(" xmlns:" + attr.Name.Local + `="`)
+		case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+			b.WriteString(` xmlns="`)
+		default:
+			continue
+		}
+
+		err := xml.EscapeText(&b, []byte(attr.Value))
+		if err != nil {
+			return nil, err
+		}
+		b.WriteString(`"`)
+	}
+	b.WriteString(">")
+	b.Write(body)
+	b.WriteString("</scope>")
+
+	return &b, nil
+}
+
+// detailElement returns the name of the first element inside the detail of the fault in body, read within the
+// namespaces in scope of the body
+func detailElement(body []byte, namespaces []xml.Attr) (xml.Name, error) {
+	b, err := scopeBody(body, namespaces)
+	if err != nil {
+		return xml.Name{}, err
+	}
+
+	// The detail element is the child of the Fault, its first child the element sought
+	dec := xml.NewDecoder(b)
+	var depth int
+	var inDetail bool
+	for {
+		t, err := dec.Token()
+		if err == io.EOF {
+			return xml.Name{}, fmt.Errorf("no element found")
+		}
+		if err != nil {
+			return xml.Name{}, err
+		}
+
+		switch t := t.(type) {
+		case xml.StartElement:
+			depth++
+			switch depth {
+			case 3:
+				inDetail = t.Name.Local == "detail" || t.Name.Local == "Detail"
+			case 4:
+				if inDetail {
+					return t.Name, nil
+				}
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+}
+
+// matchFault sets the name of the declared fault whose message element is the first element of the detail. If the
+// prefix of the detail element was not declared anywhere in the response, it is matched by its local name alone, as
+// long as just one declared fault has that name.
+func (f *Fault) matchFault(faults map[string]xml.Name) {
+	if f.Element.Local == "" {
+		return
+	}
+
+	names := make([]string, 0, len(faults))
+	for name := range faults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var local []string
+	for _, name := range names {
+		element := faults[name]
+		if element == f.Element {
+			f.Name = name
+			return
+		}
+
+		if element.Local == f.Element.Local {
+			local = append(local, name)
+		}
+	}
+
+	// An unresolved prefix is kept as the namespace, which unlike a namespace URI has no colon
+	if len(local) == 1 && !strings.Contains(f.Element.Space, ":") {
+		f.Name = local[0]
+	}
+}
+
+// firstElement returns the first element of body, read within the namespaces in scope of the body
+func firstElement(body []byte, namespaces []xml.Attr) (xml.StartElement, error) {
+	b, err := scopeBody(body, namespaces)
+	if err != nil {
+		return xml.StartElement{}, err
+	}
+
+	dec := xml.NewDecoder(b)
+	var depth int
+	for {
+		t, err := dec.Token()
+		if err == io.EOF {
+			return xml.StartElement{}, fmt.Errorf("no element found")
+		}
+		if err != nil {
+			return xml.StartElement{}, err
+		}
+
+		if start, ok := t.(xml.StartElement); ok {
+			depth++
+			if depth == 2 {
+				return start, nil
+			}
+		}
+	}
+}
+
+func localName(qname string) string {
+	if i := strings.LastIndex(qname, ":"); i >= 0 {
+		return qname[i+1:]
+	}
+
+	return qname
+}
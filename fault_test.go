@@ -0,0 +1,298 @@
+package goat
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sezzle/goat/client"
+	"github.com/sezzle/goat/wsdl"
+	"github.com/sezzle/sezzle-go-xml"
+)
+
+func TestWebservice_Do_Fault_Soap11WithDetail(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/customer_service.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockResponseString := `<?xml version="1.0"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+	<soap:Body>
+		<soap:Fault>
+			<faultcode>soap:Server</faultcode>
+			<faultstring>[AuthenticationError.CLIENT_CUSTOMER_ID_INVALID @ ; trigger:'']</faultstring>
+			<detail>
+				<ApiExceptionFault xmlns="https://adwords.google.com/api/adwords/mcm/v201509">
+					<message>[AuthenticationError.CLIENT_CUSTOMER_ID_INVALID @ ; trigger:'']</message>
+					<errors>
+						<fieldPath></fieldPath>
+						<trigger></trigger>
+						<errorString>AuthenticationError.CLIENT_CUSTOMER_ID_INVALID</errorString>
+					</errors>
+				</ApiExceptionFault>
+			</detail>
+		</soap:Fault>
+	</soap:Body>
+</soap:Envelope>`
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusInternalServerError, Body: ioutil.NopCloser(bytes.NewBuffer([]byte(mockResponseString)))}, nil).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	var responseString string
+	err = testService.Do("ManagedCustomerService", "get", &responseString, map[string]interface{}{})
+	fault, ok := err.(*Fault)
+	if !ok {
+		t.Fatalf("Expected a *Fault from testService.Do, got %+v", err)
+	}
+
+	if fault.StatusCode != http.StatusInternalServerError || fault.Code != "soap:Server" || fault.LocalCode() != "Server" {
+		t.Errorf("Unexpected fault code %+v", fault)
+	}
+
+	if !strings.Contains(fault.Reason, "CLIENT_CUSTOMER_ID_INVALID") {
+		t.Errorf("Unexpected fault reason %s", fault.Reason)
+	}
+
+	if fault.Name != "ApiException" || fault.Element.Local != "ApiExceptionFault" {
+		t.Errorf("Expected the detail to match the declared ApiException fault, got '%s' for %+v", fault.Name, fault.Element)
+	}
+
+	detail := struct {
+		Message string `xml:"message"`
+		Errors  []struct {
+			ErrorString string `xml:"errorString"`
+		} `xml:"errors"`
+	}{}
+	err = fault.DecodeDetail(&detail)
+	if err != nil {
+		t.Errorf("Expected nil error decoding the fault detail, got %+v", err)
+	}
+
+	if len(detail.Errors) != 1 || detail.Errors[0].ErrorString != "AuthenticationError.CLIENT_CUSTOMER_ID_INVALID" {
+		t.Errorf("Unexpected fault detail %+v", detail)
+	}
+}
+
+func TestWebservice_Do_Fault_Soap11EnvelopeNamespace(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/customer_service.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	// Both declared faults have an ApiExceptionFault element, the prefix declared on the envelope tells them apart
+	mockResponseString := `<?xml version="1.0"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/" xmlns:cm="https://adwords.google.com/api/adwords/cm/v201509">
+	<soap:Body>
+		<soap:Fault>
+			<faultcode>soap:Server</faultcode>
+			<faultstring>Internal error</faultstring>
+			<detail>
+				<cm:ApiExceptionFault>Internal error</cm:ApiExceptionFault>
+			</detail>
+		</soap:Fault>
+	</soap:Body>
+</soap:Envelope>`
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusInternalServerError, Body: ioutil.NopCloser(bytes.NewBuffer([]byte(mockResponseString)))}, nil).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	var responseString string
+	err = testService.Do("ManagedCustomerService", "get", &responseString, map[string]interface{}{})
+	fault, ok := err.(*Fault)
+	if !ok {
+		t.Fatalf("Expected a *Fault from testService.Do, got %+v", err)
+	}
+
+	expectedElement := xml.Name{Space: "https://adwords.google.com/api/adwords/cm/v201509", Local: "ApiExceptionFault"}
+	if fault.Name != "CommonApiException" || fault.Element != expectedElement {
+		t.Errorf("Expected the detail to match the declared CommonApiException fault, got '%s' for %+v", fault.Name, fault.Element)
+	}
+}
+
+func TestWebservice_Do_Fault_Soap12WithStatusOK(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/ec2.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockResponseString := `<?xml version="1.0"?>
+<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope">
+	<env:Body>
+		<env:Fault>
+			<env:Code>
+				<env:Value>env:Sender</env:Value>
+				<env:Subcode>
+					<env:Value>ec2:InvalidParameterValue</env:Value>
+				</env:Subcode>
+			</env:Code>
+			<env:Reason>
+				<env:Text xml:lang="en">Invalid network interface ID</env:Text>
+			</env:Reason>
+			<env:Role>http://ec2.amazonaws.com/</env:Role>
+		</env:Fault>
+	</env:Body>
+</env:Envelope>`
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer([]byte(mockResponseString)))}, nil).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	params := map[string]interface{}{
+		"ModifyNetworkInterfaceAttribute/networkInterfaceId":             "1234512345",
+		"ModifyNetworkInterfaceAttribute/attachment/attachmentId":        "1234512345",
+		"ModifyNetworkInterfaceAttribute/attachment/deleteOnTermination": true,
+	}
+
+	var responseString string
+	err = testService.Do("AmazonEC2", "ModifyNetworkInterfaceAttribute", &responseString, params)
+	fault, ok := err.(*Fault)
+	if !ok {
+		t.Fatalf("Expected a *Fault from testService.Do, got %+v", err)
+	}
+
+	if fault.LocalCode() != "Sender" || len(fault.Subcodes) != 1 || fault.Subcodes[0] != "ec2:InvalidParameterValue" {
+		t.Errorf("Unexpected fault code %+v", fault)
+	}
+
+	if fault.Reason != "Invalid network interface ID" || fault.Role != "http://ec2.amazonaws.com/" {
+		t.Errorf("Unexpected fault %+v", fault)
+	}
+}
+
+func TestWebservice_Do_HappyPath_NonEnvelopeFault(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/customer_service.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	// A response element called Fault, which is not in the namespace of the envelope, is no SOAP fault
+	mockResponseString := `<?xml version="1.0"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+	<soap:Body>
+		<x:Fault xmlns:x="urn:business">
+			<faultcode>x</faultcode>
+		</x:Fault>
+	</soap:Body>
+</soap:Envelope>`
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer([]byte(mockResponseString)))}, nil).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	res := struct {
+		XMLName   xml.Name `xml:"urn:business Fault"`
+		FaultCode string   `xml:"faultcode"`
+	}{}
+	err = testService.Do("ManagedCustomerService", "get", &res, map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("Expected nil error from testService.Do, got %+v", err)
+	}
+
+	if res.FaultCode != "x" {
+		t.Errorf("Unexpected response %+v", res)
+	}
+}
+
+func TestWebservice_Do_ErrorPath_NonSoapError(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/ec2.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusBadGateway, Body: ioutil.NopCloser(bytes.NewBuffer([]byte("bad gateway")))}, nil).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	params := map[string]interface{}{
+		"ModifyNetworkInterfaceAttribute/networkInterfaceId":             "1234512345",
+		"ModifyNetworkInterfaceAttribute/attachment/attachmentId":        "1234512345",
+		"ModifyNetworkInterfaceAttribute/attachment/deleteOnTermination": true,
+	}
+
+	var responseString string
+	err = testService.Do("AmazonEC2", "ModifyNetworkInterfaceAttribute", &responseString, params)
+	respErr, ok := err.(*client.ResponseError)
+	if !ok {
+		t.Fatalf("Expected a *client.ResponseError from testService.Do, got %+v", err)
+	}
+
+	if respErr.StatusCode != http.StatusBadGateway || respErr.Error() != "bad gateway" {
+		t.Errorf("Unexpected response error %+v", respErr)
+	}
+}
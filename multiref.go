@@ -0,0 +1,149 @@
+package goat
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/sezzle/sezzle-go-xml"
+)
+
+// refNode is an element of a SOAP encoded body, kept as written to inline the multi-reference values it refers to
+type refNode struct {
+	start    xml.StartElement
+	children []interface{} // *refNode or xml.CharData
+}
+
+// resolveMultiRefs inlines the multi-reference values of a SOAP encoded body. Every accessor with href="#id" gets the
+// attributes and content of the element with id="id", and the top level elements holding those values are dropped,
+// so the body decodes like a literal one.
+func resolveMultiRefs(data []byte) ([]byte, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	var roots, stack []*refNode
+	ids := map[string]*refNode{}
+	for {
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &refNode{start: t.Copy()}
+			if id, ok := refAttr(t, "id"); ok {
+				ids[id] = n
+			}
+
+			if len(stack) == 0 {
+				roots = append(roots, n)
+			} else {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, n)
+			}
+			stack = append(stack, n)
+		case xml.EndElement:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, t.Copy())
+			}
+		}
+	}
+
+	var b bytes.Buffer
+	for _, n := range roots {
+		if _, ok := refAttr(n.start, "id"); ok {
+			continue
+		}
+
+		err := writeRefNode(&b, n, ids, map[*refNode]bool{})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return b.Bytes(), nil
+}
+
+// refAttr returns the value of the unqualified attribute local of start
+func refAttr(start xml.StartElement, local string) (string, bool) {
+	for _, attr := range start.Attr {
+		if attr.Name.Space == "" && attr.Name.Local == local {
+			return attr.Value, true
+		}
+	}
+
+	return "", false
+}
+
+// writeRefNode writes n, replacing a reference by the value it refers to. visiting holds the values currently being
+// written, a reference to one of them is cyclic and kept as it is.
+func writeRefNode(b *bytes.Buffer, n *refNode, ids map[string]*refNode, visiting map[*refNode]bool) error {
+	attrs, children := n.start.Attr, n.children
+	if href, ok := refAttr(n.start, "href"); ok && strings.HasPrefix(href, "#") {
+		if target := ids[href[1:]]; target != nil && !visiting[target] {
+			visiting[target] = true
+			defer delete(visiting, target)
+
+			attrs = nil
+			for _, attr := range n.start.Attr {
+				if attr.Name.Space != "" || attr.Name.Local != "href" {
+					attrs = append(attrs, attr)
+				}
+			}
+			for _, attr := range target.start.Attr {
+				// The id and the soapenc:root of the value only apply to the top level element holding it
+				if attr.Name.Space == "" && attr.Name.Local == "id" || attr.Name.Space != "" && attr.Name.Space != "xmlns" && attr.Name.Local == "root" {
+					continue
+				}
+				attrs = append(attrs, attr)
+			}
+			children = target.children
+		}
+	}
+
+	name := rawName(n.start.Name)
+	b.WriteString("<" + name)
+	for _, attr := range attrs {
+		b.WriteString(" " + rawName(attr.Name) + `="`)
+		err := xml.EscapeText(b, []byte(attr.Value))
+		if err != nil {
+			return err
+		}
+		b.WriteString(`"`)
+	}
+	b.WriteString(">")
+
+	for _, child := range children {
+		switch c := child.(type) {
+		case *refNode:
+			err := writeRefNode(b, c, ids, visiting)
+			if err != nil {
+				return err
+			}
+		case xml.CharData:
+			err := xml.EscapeText(b, c)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	b.WriteString("</" + name + ">")
+	return nil
+}
+
+// rawName returns a name read by RawToken as written, whose Space is its prefix
+func rawName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+
+	return name.Space + ":" + name.Local
+}
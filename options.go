@@ -0,0 +1,67 @@
+package goat
+
+// Option changes the way a single request is built or sent by NewRequest, SendBuffer and Do
+type Option func(*requestOptions)
+
+type requestOptions struct {
+	headerParams map[string]interface{}
+	soapAction   *string
+	strict       bool
+	port         string
+}
+
+// WithHeaderParams : Sets SOAP header params for a single call. They are merged over the header params submitted
+// to Webservice.UseHeaderParams, so a single call can override any of the global defaults.
+func WithHeaderParams(params map[string]interface{}) Option {
+	return func(o *requestOptions) {
+		o.headerParams = mergeParams(o.headerParams, params)
+	}
+}
+
+// WithSOAPAction : Overrides the soapAction of the binding operation for a single call. It is sent as SOAPAction
+// header for SOAP 1.1 and as action parameter of the Content-Type for SOAP 1.2.
+func WithSOAPAction(action string) Option {
+	return func(o *requestOptions) {
+		o.soapAction = &action
+	}
+}
+
+// WithStrictParams : Overrides Webservice.UseStrictParams for a single call
+func WithStrictParams(strict bool) Option {
+	return func(o *requestOptions) {
+		o.strict = strict
+	}
+}
+
+// WithPort : Sends a single call to the named port of the service, instead of its default port. The default port is
+// the first one bound to SOAP 1.1, or the first port of the service if there is none.
+func WithPort(port string) Option {
+	return func(o *requestOptions) {
+		o.port = port
+	}
+}
+
+func (w *Webservice) newRequestOptions(opts []Option) *requestOptions {
+	o := &requestOptions{
+		headerParams: mergeParams(nil, w.headerParams),
+		strict:       w.strictParams,
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+func mergeParams(dst, src map[string]interface{}) map[string]interface{} {
+	if dst == nil {
+		dst = map[string]interface{}{}
+	}
+
+	for k, v := range src {
+		dst[k] = v
+	}
+
+	return dst
+}
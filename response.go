@@ -2,63 +2,209 @@ package goat
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"github.com/sezzle/goat/client"
+	"github.com/sezzle/goat/wsdl"
+	"github.com/sezzle/goat/xsd"
 	"github.com/sezzle/sezzle-go-xml"
 	"io"
+	"log"
+	"net/http"
 )
 
 type ResponseEnvelope struct {
-	XMLName xml.Name `xml:"Envelope"`
+	XMLName xml.Name   `xml:"Envelope"`
+	Attrs   []xml.Attr `xml:",any,attr"` // The namespace declarations of the envelope, among its other attributes
 	Header  struct {
 		XMLName xml.Name `xml:"Header"`
 		Data    []byte   `xml:",innerxml"`
 	}
 	Body struct {
-		XMLName xml.Name `xml:"Body"`
-		Data    []byte   `xml:",innerxml"`
+		XMLName xml.Name   `xml:"Body"`
+		Attrs   []xml.Attr `xml:",any,attr"`
+		Data    []byte     `xml:",innerxml"`
+	}
+}
+
+// namespaces returns the attributes in scope of the content of the body, which declare the prefixes it may use
+func (e *ResponseEnvelope) namespaces() []xml.Attr {
+	return append(append([]xml.Attr{}, e.Attrs...), e.Body.Attrs...)
+}
+
+// NewRequest : Encodes and does some validations, writing an XML request to the supplied buffer. params is either a
+// map of slash paths like "get/serviceSelector/fields", or a struct or a pointer to one with xml tags mapping its
+// fields onto the schema.
+func (w *Webservice) NewRequest(service, method string, params interface{}, buf io.Writer, opts ...Option) error {
+	return w.newRequest(context.Background(), service, method, params, buf, w.newRequestOptions(opts))
+}
+
+func (w *Webservice) newRequest(ctx context.Context, service, method string, params interface{}, buf io.Writer, o *requestOptions) error {
+	p, err := w.port(service, o)
+	if err != nil {
+		return err
+	}
+
+	err = p.WriteRequest(method, &contextWriter{ctx: ctx, w: buf}, o.headerParams, params)
+	if _, ok := err.(xsd.UnknownParamsError); ok && !o.strict {
+		log.Printf("sending '%s' to '%s' without unknown params: %v", method, service, err)
+		err = nil
 	}
+	if err != nil {
+		return err
+	}
+
+	return ctx.Err()
 }
 
-// NewRequest : Encodes and does some validations, writing an XML request to the supplied buffer
-func (w *Webservice) NewRequest(service, method string, params map[string]interface{}, buf io.Writer) error {
+// port returns the port of the service a call is sent to, selected by WithPort
+func (w *Webservice) port(service string, o *requestOptions) (*wsdl.Port, error) {
 	s := w.services[service]
 	if s == nil {
 		err := fmt.Errorf("no such service '%s'", service)
+		return nil, err
+	}
+
+	return s.Port(service, o.port)
+}
+
+// SendBuffer : Posts the SOAP request in buf to the service and decodes the response body into res. SOAP faults are
+// returned as *Fault. Since the operation is unknown, the SOAPAction is empty unless set by WithSOAPAction.
+func (w *Webservice) SendBuffer(service string, res interface{}, buf io.Reader, opts ...Option) error {
+	return w.SendBufferContext(context.Background(), service, res, buf, opts...)
+}
+
+// SendBufferContext : Like SendBuffer, but the request is bound to ctx
+func (w *Webservice) SendBufferContext(ctx context.Context, service string, res interface{}, buf io.Reader, opts ...Option) error {
+	o := w.newRequestOptions(opts)
+	p, err := w.port(service, o)
+	if err != nil {
+		return err
+	}
+
+	return w.send(ctx, p, "", res, buf, o)
+}
+
+func (w *Webservice) Do(service, method string, res interface{}, params interface{}, opts ...Option) error {
+	return w.DoContext(context.Background(), service, method, res, params, opts...)
+}
+
+// DoContext : Like Do, but encoding the request, sending it and decoding the response are aborted once ctx is done
+func (w *Webservice) DoContext(ctx context.Context, service, method string, res interface{}, params interface{}, opts ...Option) error {
+	o := w.newRequestOptions(opts)
+	buf := new(bytes.Buffer)
+	err := w.newRequest(ctx, service, method, params, buf, o)
+	if err != nil {
 		return err
 	}
 
-	err := s.WriteRequest(method, buf, params)
+	p, err := w.port(service, o)
+	if err != nil {
+		return err
+	}
+
+	err = w.send(ctx, p, method, res, buf, o)
 	return err
 }
 
-func (w *Webservice) SendBuffer(service string, res interface{}, buf io.Reader) error {
-	s := w.services[service]
-	if s == nil {
-		err := fmt.Errorf("no such service '%s'", service)
+// send posts buf to the port. If method is set, the detail of a fault is matched against the faults declared
+// for the operation.
+func (w *Webservice) send(ctx context.Context, s *wsdl.Port, method string, res interface{}, buf io.Reader, o *requestOptions) error {
+	version, err := s.GetSoapVersion()
+	if err != nil {
 		return err
 	}
 
+	var action string
+	switch {
+	case o.soapAction != nil:
+		action = *o.soapAction
+	case method != "":
+		action, err = s.GetSoapAction(method)
+		if err != nil {
+			return err
+		}
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", version.ContentType(action))
+	if version == wsdl.Soap11 {
+		header.Set("SOAPAction", fmt.Sprintf("%q", action))
+	}
+
 	e := new(ResponseEnvelope)
-	err := w.client.MakeRequest("POST", s.Service.Port.Address.Location, buf, e)
+	err = w.client.MakeRequestContext(ctx, "POST", s.Location(), header, buf, e)
+	if err != nil {
+		respErr, ok := err.(*client.ResponseError)
+		if !ok {
+			return err
+		}
+
+		// Faults are sent with a 500, but anything which can't be read as a fault keeps the original error
+		fault := new(ResponseEnvelope)
+		if xml.Unmarshal(respErr.Body, fault) != nil {
+			return err
+		}
+
+		f, ferr := parseFault(fault.Body.Data, fault.namespaces())
+		if ferr != nil || f == nil {
+			return err
+		}
+
+		f.StatusCode = respErr.StatusCode
+		return w.matchFault(s, method, f)
+	}
+
+	// Some services send their faults with a 200
+	f, err := parseFault(e.Body.Data, e.namespaces())
 	if err != nil {
 		return err
 	}
+	if f != nil {
+		f.StatusCode = http.StatusOK
+		return w.matchFault(s, method, f)
+	}
+
+	// SOAP encoded responses may refer to values sent apart from their accessors, which are put in place first
+	data := e.Body.Data
+	if method != "" && s.EncodedOutput(method) {
+		data, err = resolveMultiRefs(data)
+		if err != nil {
+			return err
+		}
+	}
 
-	err = xml.Unmarshal(e.Body.Data, res)
+	err = xml.NewDecoder(client.NewContextReader(ctx, bytes.NewReader(data))).Decode(res)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (w *Webservice) Do(service, method string, res interface{}, params map[string]interface{}) error {
-	buf := new(bytes.Buffer)
-	err := w.NewRequest(service, method, params, buf)
+func (w *Webservice) matchFault(s *wsdl.Port, method string, f *Fault) error {
+	if method == "" {
+		return f
+	}
+
+	faults, err := s.GetFaults(method)
 	if err != nil {
-		return err
+		return f
 	}
 
-	// TODO: Webservice.SendBuffer with invalid SOAP request will return a generic SOAP faultCode
-	err = w.SendBuffer(service, res, buf)
-	return err
+	f.matchFault(faults)
+	return f
+}
+
+// contextWriter fails every write once its context is done, which aborts encoding a request
+type contextWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (w *contextWriter) Write(p []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return w.w.Write(p)
 }
@@ -2,14 +2,20 @@ package goat
 
 import (
 	"bytes"
+	"context"
 	"io/ioutil"
+	"math/big"
 	"net/http"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/sezzle/goat/client"
 	"github.com/sezzle/goat/wsdl"
+	"github.com/sezzle/goat/xsd"
+	"github.com/sezzle/sezzle-go-xml"
 )
 
 func TestWebservice_Do_ErrorPath_NoServiceFound(t *testing.T) {
@@ -223,7 +229,7 @@ func TestWebservice_Do_ErrorPath_MoreThanOneChoice(t *testing.T) {
 	}
 }
 
-func TestWebservice_Do_HappyPath_NoChoices(t *testing.T) {
+func TestWebservice_Do_ErrorPath_NoChoice(t *testing.T) {
 	testWSDL, err := ioutil.ReadFile("fixtures/ec2.wsdl")
 	if err != nil {
 		t.Errorf("Error reading wsdl file")
@@ -245,7 +251,7 @@ func TestWebservice_Do_HappyPath_NoChoices(t *testing.T) {
 </soap:Envelope>`
 
 	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
-	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer([]byte(mockResponseString)))}, nil).Times(1)
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer([]byte(mockResponseString)))}, nil).Times(0)
 
 	testService := Webservice{
 		services: map[string]*wsdl.Definitions{},
@@ -263,8 +269,12 @@ func TestWebservice_Do_HappyPath_NoChoices(t *testing.T) {
 
 	var responseString string
 	err = testService.Do("AmazonEC2", "ModifyNetworkInterfaceAttribute", &responseString, params)
-	if err != nil {
-		t.Errorf("Expected nil error from testService.Do for no choices submitted")
+	if err == nil {
+		t.Errorf("Expected an error from testService.Do for no choices submitted to a required choice")
+	}
+
+	if err != nil && !strings.Contains(err.Error(), "one of the choice elements") {
+		t.Errorf("Unexpected error for %+v", err)
 	}
 }
 
@@ -365,11 +375,11 @@ func TestWebservice_NewRequest_HappyPath_WithChoice(t *testing.T) {
 <soap-env:Envelope xmlns:soap-env="http://schemas.xmlsoap.org/soap/envelope/">
   <soap-env:Body>
     <ns0:ModifyNetworkInterfaceAttribute xmlns:ns0="http://ec2.amazonaws.com/doc/2013-10-15/">
-      <networkInterfaceId>1234512345</networkInterfaceId>
-      <attachment>
-        <attachmentId>1234512345</attachmentId>
-        <deleteOnTermination>true</deleteOnTermination>
-      </attachment>
+      <ns0:networkInterfaceId>1234512345</ns0:networkInterfaceId>
+      <ns0:attachment>
+        <ns0:attachmentId>1234512345</ns0:attachmentId>
+        <ns0:deleteOnTermination>true</ns0:deleteOnTermination>
+      </ns0:attachment>
     </ns0:ModifyNetworkInterfaceAttribute>
   </soap-env:Body>
 </soap-env:Envelope>`
@@ -378,3 +388,2477 @@ func TestWebservice_NewRequest_HappyPath_WithChoice(t *testing.T) {
 		t.Errorf("Unexpected XML request")
 	}
 }
+
+func TestWebservice_NewRequest_HappyPath_WithHeader(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/customer_service.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	testService.UseHeaderParams(map[string]interface{}{
+		"RequestHeader/clientCustomerId": "123-456-7890",
+		"RequestHeader/developerToken":   "DEVELOPER_TOKEN",
+		"RequestHeader/validateOnly":     false,
+	})
+
+	params := map[string]interface{}{
+		"get/serviceSelector/fields": "Name",
+	}
+
+	buf := new(bytes.Buffer)
+	err = testService.NewRequest("ManagedCustomerService", "get", params, buf, WithHeaderParams(map[string]interface{}{
+		"RequestHeader/validateOnly": true,
+	}))
+	if err != nil {
+		t.Errorf("Expected nil error from testService.NewRequest with header params, got %+v", err)
+	}
+
+	expectedRequestString := `<?xml version="1.0" encoding="UTF-8"?>
+<soap-env:Envelope xmlns:soap-env="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap-env:Header>
+    <ns0:RequestHeader xmlns:ns0="https://adwords.google.com/api/adwords/mcm/v201509">
+      <ns0:clientCustomerId>123-456-7890</ns0:clientCustomerId>
+      <ns0:developerToken>DEVELOPER_TOKEN</ns0:developerToken>
+      <ns0:validateOnly>true</ns0:validateOnly>
+    </ns0:RequestHeader>
+  </soap-env:Header>
+  <soap-env:Body>
+    <ns0:get xmlns:ns0="https://adwords.google.com/api/adwords/mcm/v201509">
+      <ns0:serviceSelector>
+        <ns0:fields>Name</ns0:fields>
+      </ns0:serviceSelector>
+    </ns0:get>
+  </soap-env:Body>
+</soap-env:Envelope>`
+
+	if buf.String() != expectedRequestString {
+		t.Errorf("Unexpected XML request %s", buf.String())
+	}
+
+	buf = new(bytes.Buffer)
+	testService.UseHeaderParams(nil)
+	err = testService.NewRequest("ManagedCustomerService", "get", params, buf)
+	if err != nil {
+		t.Errorf("Expected nil error from testService.NewRequest without header params, got %+v", err)
+	}
+
+	if strings.Contains(buf.String(), "Header") {
+		t.Errorf("Expected no soap header without header params %s", buf.String())
+	}
+}
+
+func TestWebservice_Do_HappyPath_Soap12(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/calculator_soap12.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockResponseString := `<?xml version="1.0" encoding="utf-8"?>
+<soap:Envelope xmlns:soap="http://www.w3.org/2003/05/soap-envelope">
+	<soap:Body>
+		<AddResponse xmlns="http://tempuri.org/">
+			<AddResult>5</AddResult>
+		</AddResponse>
+	</soap:Body>
+</soap:Envelope>`
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+	mockClient.EXPECT().Do(gomock.Any()).DoAndReturn(func(req *http.Request) (*http.Response, error) {
+		if req.Header.Get("Content-Type") != `application/soap+xml; charset=utf-8; action="http://tempuri.org/Add"` {
+			t.Errorf("Unexpected content type %s", req.Header.Get("Content-Type"))
+		}
+
+		if req.Header.Get("SOAPAction") != "" {
+			t.Errorf("Expected no SOAPAction header for SOAP 1.2")
+		}
+
+		body, _ := ioutil.ReadAll(req.Body)
+		if !strings.Contains(string(body), `<soap-env:Envelope xmlns:soap-env="http://www.w3.org/2003/05/soap-envelope">`) {
+			t.Errorf("Expected a SOAP 1.2 envelope %s", body)
+		}
+
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer([]byte(mockResponseString)))}, nil
+	}).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	params := map[string]interface{}{
+		"Add/intA": 2,
+		"Add/intB": 3,
+	}
+
+	resp := struct {
+		AddResult int `xml:"AddResult"`
+	}{}
+	err = testService.Do("Calculator", "Add", &resp, params)
+	if err != nil {
+		t.Errorf("Expected nil error from testService.Do for a SOAP 1.2 request, got %+v", err)
+	}
+
+	if resp.AddResult != 5 {
+		t.Errorf("Unexpected response %+v", resp)
+	}
+}
+
+func TestWebservice_Do_HappyPath_SOAPAction(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/ec2.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockResponseString := `
+<?xml version="1.0"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+	<soap:Body>
+		<ModifyNetworkInterfaceAttributeResponse xmlns="http://ec2.amazonaws.com/doc/2013-10-15/">
+			<requestId>59dbff89-35bd-4eac-99ed-be587EXAMPLE</requestId>
+			<return>true</return>
+		</ModifyNetworkInterfaceAttributeResponse>
+	</soap:Body>
+</soap:Envelope>`
+
+	var soapActions []string
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+	mockClient.EXPECT().Do(gomock.Any()).DoAndReturn(func(req *http.Request) (*http.Response, error) {
+		if req.Header.Get("Content-Type") != "text/xml; charset=utf-8" {
+			t.Errorf("Unexpected content type %s", req.Header.Get("Content-Type"))
+		}
+
+		soapActions = append(soapActions, req.Header.Get("SOAPAction"))
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer([]byte(mockResponseString)))}, nil
+	}).Times(2)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	params := map[string]interface{}{
+		"ModifyNetworkInterfaceAttribute/networkInterfaceId":             "1234512345",
+		"ModifyNetworkInterfaceAttribute/attachment/attachmentId":        "1234512345",
+		"ModifyNetworkInterfaceAttribute/attachment/deleteOnTermination": true,
+	}
+
+	var responseString string
+	err = testService.Do("AmazonEC2", "ModifyNetworkInterfaceAttribute", &responseString, params)
+	if err != nil {
+		t.Errorf("Expected nil error from testService.Do, got %+v", err)
+	}
+
+	err = testService.Do("AmazonEC2", "ModifyNetworkInterfaceAttribute", &responseString, params, WithSOAPAction("urn:ModifyNetworkInterfaceAttribute"))
+	if err != nil {
+		t.Errorf("Expected nil error from testService.Do with an overridden SOAPAction, got %+v", err)
+	}
+
+	if len(soapActions) != 2 || soapActions[0] != `"ModifyNetworkInterfaceAttribute"` || soapActions[1] != `"urn:ModifyNetworkInterfaceAttribute"` {
+		t.Errorf("Unexpected SOAPAction headers %q", soapActions)
+	}
+}
+
+func TestWebservice_DoContext_ErrorPath_Cancelled(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/ec2.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	params := map[string]interface{}{
+		"ModifyNetworkInterfaceAttribute/networkInterfaceId":             "1234512345",
+		"ModifyNetworkInterfaceAttribute/attachment/attachmentId":        "1234512345",
+		"ModifyNetworkInterfaceAttribute/attachment/deleteOnTermination": true,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var responseString string
+	err = testService.DoContext(ctx, "AmazonEC2", "ModifyNetworkInterfaceAttribute", &responseString, params)
+	if err != context.Canceled {
+		t.Errorf("Expected context.Canceled from testService.DoContext before sending the request, got %+v", err)
+	}
+}
+
+func TestWebservice_NewRequest_HappyPath_RepeatedElement(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/ec2.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	params := map[string]interface{}{
+		"UnassignPrivateIpAddresses/networkInterfaceId":                          "1234512345",
+		"UnassignPrivateIpAddresses/privateIpAddressesSet/item/privateIpAddress": []string{"10.0.0.1", "10.0.0.2"},
+	}
+
+	buf := new(bytes.Buffer)
+	err = testService.NewRequest("AmazonEC2", "UnassignPrivateIpAddresses", params, buf)
+	if err != nil {
+		t.Errorf("Expected nil error from testService.NewRequest for repeated elements, got %+v", err)
+	}
+
+	expectedRequestString := `<?xml version="1.0" encoding="UTF-8"?>
+<soap-env:Envelope xmlns:soap-env="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap-env:Body>
+    <ns0:UnassignPrivateIpAddresses xmlns:ns0="http://ec2.amazonaws.com/doc/2013-10-15/">
+      <ns0:networkInterfaceId>1234512345</ns0:networkInterfaceId>
+      <ns0:privateIpAddressesSet>
+        <ns0:item>
+          <ns0:privateIpAddress>10.0.0.1</ns0:privateIpAddress>
+        </ns0:item>
+        <ns0:item>
+          <ns0:privateIpAddress>10.0.0.2</ns0:privateIpAddress>
+        </ns0:item>
+      </ns0:privateIpAddressesSet>
+    </ns0:UnassignPrivateIpAddresses>
+  </soap-env:Body>
+</soap-env:Envelope>`
+
+	if buf.String() != expectedRequestString {
+		t.Errorf("Unexpected XML request %s", buf.String())
+	}
+}
+
+func TestWebservice_Do_ErrorPath_ValidationErrors(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/ec2.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	params := map[string]interface{}{
+		"ModifyNetworkInterfaceAttribute/networkInterfaceId":    []string{"1234512345", "5432154321"},
+		"ModifyNetworkInterfaceAttribute/description/value":     "too many choices",
+		"ModifyNetworkInterfaceAttribute/sourceDestCheck/value": true,
+	}
+
+	var responseString string
+	err = testService.Do("AmazonEC2", "ModifyNetworkInterfaceAttribute", &responseString, params)
+	validationErrors, ok := err.(xsd.ValidationErrors)
+	if !ok {
+		t.Fatalf("Expected xsd.ValidationErrors from testService.Do, got %+v", err)
+	}
+
+	if len(validationErrors) != 2 {
+		t.Fatalf("Expected exactly two validation errors, got %+v", validationErrors)
+	}
+
+	if validationErrors[0].Path != "ModifyNetworkInterfaceAttribute/networkInterfaceId" || !strings.Contains(validationErrors[0].Message, "exceeds maxOccurs 1") {
+		t.Errorf("Unexpected maxOccurs validation error %+v", validationErrors[0])
+	}
+
+	if validationErrors[1].Path != "ModifyNetworkInterfaceAttribute" || !strings.Contains(validationErrors[1].Message, "A max of one choice element can be submitted") {
+		t.Errorf("Unexpected choice validation error %+v", validationErrors[1])
+	}
+}
+
+func TestWebservice_NewRequest_HappyPath_UnknownParams(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/ec2.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	params := map[string]interface{}{
+		"ModifyNetworkInterfaceAttribute/networkInterfaceId":             "1234512345",
+		"ModifyNetworkInterfaceAttribute/attachment/attachmentId":        "1234512345",
+		"ModifyNetworkInterfaceAttribute/attachment/deleteOnTermination": true,
+		"ModifyNetworkInterfaceAttribute/atachment/attachmentId":         "5432154321",
+	}
+
+	buf := new(bytes.Buffer)
+	err = testService.NewRequest("AmazonEC2", "ModifyNetworkInterfaceAttribute", params, buf)
+	if err != nil {
+		t.Errorf("Expected unknown params to be ignored without strict params, got %+v", err)
+	}
+
+	expectedRequestString := `<?xml version="1.0" encoding="UTF-8"?>
+<soap-env:Envelope xmlns:soap-env="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap-env:Body>
+    <ns0:ModifyNetworkInterfaceAttribute xmlns:ns0="http://ec2.amazonaws.com/doc/2013-10-15/">
+      <ns0:networkInterfaceId>1234512345</ns0:networkInterfaceId>
+      <ns0:attachment>
+        <ns0:attachmentId>1234512345</ns0:attachmentId>
+        <ns0:deleteOnTermination>true</ns0:deleteOnTermination>
+      </ns0:attachment>
+    </ns0:ModifyNetworkInterfaceAttribute>
+  </soap-env:Body>
+</soap-env:Envelope>`
+
+	if buf.String() != expectedRequestString {
+		t.Errorf("Unexpected XML request %s", buf.String())
+	}
+}
+
+func TestWebservice_NewRequest_HappyPath_UnknownParamsRepeated(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/catalog.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	// A misspelled param below the unbounded item must not start another, empty item
+	params := map[string]interface{}{
+		"AddItems/@createdBy":           "catalog-sync",
+		"AddItems/item/@id":             1,
+		"AddItems/item/name":            "Pen",
+		"AddItems/item/discontinud":     true,
+		"AddItems/item/price/@currency": "USD",
+		"AddItems/item/price/amount":    1.5,
+	}
+
+	buf := new(bytes.Buffer)
+	err = testService.NewRequest("CatalogService", "AddItems", params, buf)
+	if err != nil {
+		t.Errorf("Expected unknown params to be ignored without strict params, got %+v", err)
+	}
+
+	expectedRequestString := `<?xml version="1.0" encoding="UTF-8"?>
+<soap-env:Envelope xmlns:soap-env="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap-env:Body>
+    <ns0:AddItems xmlns:ns0="http://example.com/catalog" version="1.0" createdBy="catalog-sync" source="api">
+      <item id="1">
+        <name>Pen</name>
+        <price currency="USD">
+          <amount>1.5</amount>
+        </price>
+      </item>
+    </ns0:AddItems>
+  </soap-env:Body>
+</soap-env:Envelope>`
+
+	if buf.String() != expectedRequestString {
+		t.Errorf("Unexpected XML request %s", buf.String())
+	}
+}
+
+func TestWebservice_NewRequest_ErrorPath_UnknownParamsStrict(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/ec2.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+	testService.UseStrictParams(true)
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	params := map[string]interface{}{
+		"ModifyNetworkInterfaceAttribute/networkInterfaceId":             "1234512345",
+		"ModifyNetworkInterfaceAttribute/attachment/attachmentId":        "1234512345",
+		"ModifyNetworkInterfaceAttribute/attachment/deleteOnTermination": true,
+		"ModifyNetworkInterfaceAttribute/atachment/attachmentId":         "5432154321",
+		"ModifyNetworkInterfaceAttribute/somethingElse":                  "value",
+	}
+
+	buf := new(bytes.Buffer)
+	err = testService.NewRequest("AmazonEC2", "ModifyNetworkInterfaceAttribute", params, buf)
+	unknownParams, ok := err.(xsd.UnknownParamsError)
+	if !ok {
+		t.Fatalf("Expected xsd.UnknownParamsError from testService.NewRequest, got %+v", err)
+	}
+
+	expectedUnknownParams := xsd.UnknownParamsError{
+		{Path: "ModifyNetworkInterfaceAttribute/atachment/attachmentId", Suggestion: "ModifyNetworkInterfaceAttribute/attachment/attachmentId"},
+		{Path: "ModifyNetworkInterfaceAttribute/somethingElse"},
+	}
+	if !reflect.DeepEqual(unknownParams, expectedUnknownParams) {
+		t.Errorf("Unexpected unknown params %+v", unknownParams)
+	}
+
+	err = testService.NewRequest("AmazonEC2", "ModifyNetworkInterfaceAttribute", params, new(bytes.Buffer), WithStrictParams(false))
+	if err != nil {
+		t.Errorf("Expected WithStrictParams(false) to ignore unknown params, got %+v", err)
+	}
+}
+
+func TestWebservice_NewRequest_ErrorPath_UnknownParamsRepeatedStrict(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/catalog.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+	testService.UseStrictParams(true)
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	params := map[string]interface{}{
+		"AddItems/@createdBy":           "catalog-sync",
+		"AddItems/item/@id":             []int{1, 2},
+		"AddItems/item/name":            []string{"Pen", "Pencil"},
+		"AddItems/item/nmae":            "Marker",
+		"AddItems/item/price/@currency": []string{"USD", "EUR"},
+		"AddItems/item/price/amount":    []float64{1.5, 0.5},
+		"AddItems/item/price/amuont":    2.5,
+	}
+
+	buf := new(bytes.Buffer)
+	err = testService.NewRequest("CatalogService", "AddItems", params, buf)
+	unknownParams, ok := err.(xsd.UnknownParamsError)
+	if !ok {
+		t.Fatalf("Expected xsd.UnknownParamsError from testService.NewRequest, got %+v", err)
+	}
+
+	expectedUnknownParams := xsd.UnknownParamsError{
+		{Path: "AddItems/item/nmae", Suggestion: "AddItems/item/name"},
+		{Path: "AddItems/item/price/amuont", Suggestion: "AddItems/item/price/amount"},
+	}
+	if !reflect.DeepEqual(unknownParams, expectedUnknownParams) {
+		t.Errorf("Unexpected unknown params %+v", unknownParams)
+	}
+}
+
+func TestWebservice_NewRequest_HappyPath_StructParams(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/ec2.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	type attributeValue struct {
+		Value string `xml:"value"`
+	}
+
+	type attachment struct {
+		AttachmentID        string `xml:"attachmentId"`
+		DeleteOnTermination bool   `xml:"deleteOnTermination"`
+	}
+
+	params := &struct {
+		XMLName            xml.Name        `xml:"ModifyNetworkInterfaceAttribute"`
+		NetworkInterfaceID string          `xml:"networkInterfaceId"`
+		Description        *attributeValue `xml:"description,omitempty"`
+		Attachment         *attachment     `xml:"attachment,omitempty"`
+		internal           string
+	}{
+		NetworkInterfaceID: "1234512345",
+		Attachment: &attachment{
+			AttachmentID:        "1234512345",
+			DeleteOnTermination: true,
+		},
+	}
+
+	buf := new(bytes.Buffer)
+	err = testService.NewRequest("AmazonEC2", "ModifyNetworkInterfaceAttribute", params, buf)
+	if err != nil {
+		t.Errorf("Expected nil error from testService.NewRequest for struct params, got %+v", err)
+	}
+
+	expectedRequestString := `<?xml version="1.0" encoding="UTF-8"?>
+<soap-env:Envelope xmlns:soap-env="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap-env:Body>
+    <ns0:ModifyNetworkInterfaceAttribute xmlns:ns0="http://ec2.amazonaws.com/doc/2013-10-15/">
+      <ns0:networkInterfaceId>1234512345</ns0:networkInterfaceId>
+      <ns0:attachment>
+        <ns0:attachmentId>1234512345</ns0:attachmentId>
+        <ns0:deleteOnTermination>true</ns0:deleteOnTermination>
+      </ns0:attachment>
+    </ns0:ModifyNetworkInterfaceAttribute>
+  </soap-env:Body>
+</soap-env:Envelope>`
+
+	if buf.String() != expectedRequestString {
+		t.Errorf("Unexpected XML request %s", buf.String())
+	}
+}
+
+func TestWebservice_NewRequest_HappyPath_StructParamsRepeated(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/ec2.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	type privateIPAddress struct {
+		PrivateIPAddress string `xml:"privateIpAddress"`
+	}
+
+	params := struct {
+		NetworkInterfaceID string             `xml:"networkInterfaceId"`
+		PrivateIPAddresses []privateIPAddress `xml:"privateIpAddressesSet>item"`
+	}{
+		NetworkInterfaceID: "1234512345",
+		PrivateIPAddresses: []privateIPAddress{
+			{PrivateIPAddress: "10.0.0.1"},
+			{PrivateIPAddress: "10.0.0.2"},
+		},
+	}
+
+	buf := new(bytes.Buffer)
+	err = testService.NewRequest("AmazonEC2", "UnassignPrivateIpAddresses", params, buf)
+	if err != nil {
+		t.Errorf("Expected nil error from testService.NewRequest for struct params, got %+v", err)
+	}
+
+	expectedRequestString := `<?xml version="1.0" encoding="UTF-8"?>
+<soap-env:Envelope xmlns:soap-env="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap-env:Body>
+    <ns0:UnassignPrivateIpAddresses xmlns:ns0="http://ec2.amazonaws.com/doc/2013-10-15/">
+      <ns0:networkInterfaceId>1234512345</ns0:networkInterfaceId>
+      <ns0:privateIpAddressesSet>
+        <ns0:item>
+          <ns0:privateIpAddress>10.0.0.1</ns0:privateIpAddress>
+        </ns0:item>
+        <ns0:item>
+          <ns0:privateIpAddress>10.0.0.2</ns0:privateIpAddress>
+        </ns0:item>
+      </ns0:privateIpAddressesSet>
+    </ns0:UnassignPrivateIpAddresses>
+  </soap-env:Body>
+</soap-env:Envelope>`
+
+	if buf.String() != expectedRequestString {
+		t.Errorf("Unexpected XML request %s", buf.String())
+	}
+}
+
+func TestWebservice_NewRequest_HappyPath_Attributes(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/catalog.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	params := map[string]interface{}{
+		"AddItems/@createdBy":           "catalog-sync",
+		"AddItems/@trace":               "abc",
+		"AddItems/item/@id":             []int{1, 2},
+		"AddItems/item/@discontinued":   []bool{false, true},
+		"AddItems/item/name":            []string{"Pen", "Pencil"},
+		"AddItems/item/price/@currency": []string{"USD", "EUR"},
+		"AddItems/item/price/amount":    []float64{1.5, 0.5},
+	}
+
+	buf := new(bytes.Buffer)
+	err = testService.NewRequest("CatalogService", "AddItems", params, buf)
+	if err != nil {
+		t.Errorf("Expected nil error from testService.NewRequest for attributes, got %+v", err)
+	}
+
+	expectedRequestString := `<?xml version="1.0" encoding="UTF-8"?>
+<soap-env:Envelope xmlns:soap-env="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap-env:Body>
+    <ns0:AddItems xmlns:ns0="http://example.com/catalog" version="1.0" createdBy="catalog-sync" source="api" trace="abc">
+      <item id="1" discontinued="false">
+        <name>Pen</name>
+        <price currency="USD">
+          <amount>1.5</amount>
+        </price>
+      </item>
+      <item id="2" discontinued="true">
+        <name>Pencil</name>
+        <price currency="EUR">
+          <amount>0.5</amount>
+        </price>
+      </item>
+    </ns0:AddItems>
+  </soap-env:Body>
+</soap-env:Envelope>`
+
+	if buf.String() != expectedRequestString {
+		t.Errorf("Unexpected XML request %s", buf.String())
+	}
+}
+
+func TestWebservice_NewRequest_ErrorPath_Attributes(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/catalog.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	params := map[string]interface{}{
+		"AddItems/@version":             "2.0",
+		"AddItems/item/name":            "Pen",
+		"AddItems/item/price/@currency": "USD",
+		"AddItems/item/price/amount":    1.5,
+	}
+
+	buf := new(bytes.Buffer)
+	err = testService.NewRequest("CatalogService", "AddItems", params, buf)
+	validationErrors, ok := err.(xsd.ValidationErrors)
+	if !ok {
+		t.Fatalf("Expected xsd.ValidationErrors from testService.NewRequest, got %+v", err)
+	}
+
+	expectedErrors := xsd.ValidationErrors{
+		{Path: "AddItems/@version", Message: `attribute 'version' has the fixed value "1.0", got "2.0"`},
+		{Path: "AddItems/@createdBy", Message: "required attribute 'createdBy' is missing"},
+		{Path: "AddItems/item/@id", Message: "required attribute 'id' is missing"},
+	}
+	if !reflect.DeepEqual(validationErrors, expectedErrors) {
+		t.Errorf("Unexpected validation errors %+v", validationErrors)
+	}
+}
+
+func TestWebservice_NewRequest_ErrorPath_EmptyAttributesStrict(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/catalog.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+	testService.UseStrictParams(true)
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	params := map[string]interface{}{
+		"AddItems/@createdBy":           "catalog-sync",
+		"AddItems/@source":              "",
+		"AddItems/@trace":               "",
+		"AddItems/item/@id":             1,
+		"AddItems/item/name":            "Pen",
+		"AddItems/item/price/@currency": "USD",
+		"AddItems/item/price/amount":    1.5,
+	}
+
+	buf := new(bytes.Buffer)
+	err = testService.NewRequest("CatalogService", "AddItems", params, buf)
+	unknownParams, ok := err.(xsd.UnknownParamsError)
+	if !ok {
+		t.Fatalf("Expected xsd.UnknownParamsError from testService.NewRequest, got %+v", err)
+	}
+
+	expectedUnknownParams := xsd.UnknownParamsError{
+		{Path: "AddItems/@source", Empty: true},
+		{Path: "AddItems/@trace", Empty: true},
+	}
+	if !reflect.DeepEqual(unknownParams, expectedUnknownParams) {
+		t.Errorf("Unexpected unknown params %+v", unknownParams)
+	}
+
+	buf = new(bytes.Buffer)
+	err = testService.NewRequest("CatalogService", "AddItems", params, buf, WithStrictParams(false))
+	if err != nil {
+		t.Errorf("Expected WithStrictParams(false) to leave out empty attributes, got %+v", err)
+	}
+
+	if !strings.Contains(buf.String(), `<ns0:AddItems xmlns:ns0="http://example.com/catalog" version="1.0" createdBy="catalog-sync">`) {
+		t.Errorf("Unexpected XML request %s", buf.String())
+	}
+}
+
+func TestWebservice_NewRequest_HappyPath_Group(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/ec2.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	params := map[string]interface{}{
+		"DescribeInstanceAttribute/instanceId": "i-1234512345",
+		"DescribeInstanceAttribute/kernel":     struct{}{},
+	}
+
+	buf := new(bytes.Buffer)
+	err = testService.NewRequest("AmazonEC2", "DescribeInstanceAttribute", params, buf)
+	if err != nil {
+		t.Errorf("Expected nil error from testService.NewRequest for a group, got %+v", err)
+	}
+
+	expectedRequestString := `<?xml version="1.0" encoding="UTF-8"?>
+<soap-env:Envelope xmlns:soap-env="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap-env:Body>
+    <ns0:DescribeInstanceAttribute xmlns:ns0="http://ec2.amazonaws.com/doc/2013-10-15/">
+      <ns0:instanceId>i-1234512345</ns0:instanceId>
+      <ns0:kernel></ns0:kernel>
+    </ns0:DescribeInstanceAttribute>
+  </soap-env:Body>
+</soap-env:Envelope>`
+
+	if buf.String() != expectedRequestString {
+		t.Errorf("Unexpected XML request %s", buf.String())
+	}
+
+	err = testService.NewRequest("AmazonEC2", "DescribeInstanceAttribute", map[string]interface{}{
+		"DescribeInstanceAttribute/instanceId": "i-1234512345",
+	}, new(bytes.Buffer))
+	if err == nil || !strings.Contains(err.Error(), "one of the choice elements") {
+		t.Errorf("Expected the choice of the group to be required, got %+v", err)
+	}
+}
+
+func TestWebservice_NewRequest_HappyPath_AllAndOptionalGroup(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/catalog.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	buf := new(bytes.Buffer)
+	err = testService.NewRequest("CatalogService", "GetItems", map[string]interface{}{
+		"GetItems/filter/status":   "active",
+		"GetItems/filter/category": "pens",
+	}, buf)
+	if err != nil {
+		t.Errorf("Expected nil error from testService.NewRequest without the optional group, got %+v", err)
+	}
+
+	expectedRequestString := `<?xml version="1.0" encoding="UTF-8"?>
+<soap-env:Envelope xmlns:soap-env="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap-env:Body>
+    <ns0:GetItems xmlns:ns0="http://example.com/catalog">
+      <filter>
+        <category>pens</category>
+        <ns0:status>active</ns0:status>
+      </filter>
+    </ns0:GetItems>
+  </soap-env:Body>
+</soap-env:Envelope>`
+
+	if buf.String() != expectedRequestString {
+		t.Errorf("Unexpected XML request %s", buf.String())
+	}
+
+	buf = new(bytes.Buffer)
+	err = testService.NewRequest("CatalogService", "GetItems", map[string]interface{}{
+		"GetItems/filter/category": "pens",
+		"GetItems/offset":          20,
+		"GetItems/limit":           10,
+	}, buf)
+	if err != nil {
+		t.Errorf("Expected nil error from testService.NewRequest with the optional group, got %+v", err)
+	}
+
+	expectedRequestString = `<?xml version="1.0" encoding="UTF-8"?>
+<soap-env:Envelope xmlns:soap-env="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap-env:Body>
+    <ns0:GetItems xmlns:ns0="http://example.com/catalog">
+      <filter>
+        <category>pens</category>
+      </filter>
+      <ns1:offset xmlns:ns1="http://example.com/common">20</ns1:offset>
+      <ns1:limit xmlns:ns1="http://example.com/common">10</ns1:limit>
+    </ns0:GetItems>
+  </soap-env:Body>
+</soap-env:Envelope>`
+
+	if buf.String() != expectedRequestString {
+		t.Errorf("Unexpected XML request %s", buf.String())
+	}
+
+	err = testService.NewRequest("CatalogService", "GetItems", map[string]interface{}{
+		"GetItems/filter/category": []string{"pens", "pencils"},
+		"GetItems/offset":          20,
+	}, new(bytes.Buffer))
+	validationErrors, ok := err.(xsd.ValidationErrors)
+	if !ok {
+		t.Fatalf("Expected xsd.ValidationErrors from testService.NewRequest, got %+v", err)
+	}
+
+	expectedErrors := xsd.ValidationErrors{
+		{Path: "GetItems/filter/category", Message: "element 'category' exceeds maxOccurs 1"},
+		{Path: "GetItems/limit", Message: "did not find data 'GetItems/limit' in path"},
+	}
+	if !reflect.DeepEqual(validationErrors, expectedErrors) {
+		t.Errorf("Unexpected validation errors %+v", validationErrors)
+	}
+}
+
+func TestWebservice_NewRequest_HappyPath_NestedCompositors(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/catalog.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	params := map[string]interface{}{
+		"UpdateItem/id":                  7,
+		"UpdateItem/name":                "Pen",
+		"UpdateItem/description":         "A blue pen",
+		"UpdateItem/comment":             "renamed",
+		"UpdateItem/tagName":             []string{"color", "size"},
+		"UpdateItem/tagValue":            []string{"blue", "small"},
+		"UpdateItem/extension/@source":   "import",
+		"UpdateItem/extension/reference": "A-17",
+	}
+
+	buf := new(bytes.Buffer)
+	err = testService.NewRequest("CatalogService", "UpdateItem", params, buf)
+	if err != nil {
+		t.Errorf("Expected nil error from testService.NewRequest for nested compositors, got %+v", err)
+	}
+
+	expectedRequestString := `<?xml version="1.0" encoding="UTF-8"?>
+<soap-env:Envelope xmlns:soap-env="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap-env:Body>
+    <ns0:UpdateItem xmlns:ns0="http://example.com/catalog">
+      <id>7</id>
+      <name>Pen</name>
+      <description>A blue pen</description>
+      <comment>renamed</comment>
+      <tagName>color</tagName>
+      <tagValue>blue</tagValue>
+      <tagName>size</tagName>
+      <tagValue>small</tagValue>
+      <extension source="import">
+        <reference>A-17</reference>
+      </extension>
+    </ns0:UpdateItem>
+  </soap-env:Body>
+</soap-env:Envelope>`
+
+	if buf.String() != expectedRequestString {
+		t.Errorf("Unexpected XML request %s", buf.String())
+	}
+
+	err = testService.NewRequest("CatalogService", "UpdateItem", map[string]interface{}{
+		"UpdateItem/id":       7,
+		"UpdateItem/name":     "Pen",
+		"UpdateItem/archived": true,
+	}, new(bytes.Buffer))
+	if err == nil || !strings.Contains(err.Error(), `A max of one choice element can be submitted, got ["name" "archived"]`) {
+		t.Errorf("Expected a choice validation error for the nested sequence, got %+v", err)
+	}
+}
+
+func TestWebservice_NewRequest_HappyPath_Datatypes(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/ec2.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	params := map[string]interface{}{
+		"PurchaseReservedInstancesOffering/reservedInstancesOfferingId": "offering-1",
+		"PurchaseReservedInstancesOffering/instanceCount":               "+3",
+		"PurchaseReservedInstancesOffering/limitPrice/amount":           "1.5E2",
+	}
+
+	buf := new(bytes.Buffer)
+	err = testService.NewRequest("AmazonEC2", "PurchaseReservedInstancesOffering", params, buf)
+	if err != nil {
+		t.Errorf("Expected nil error from testService.NewRequest for datatypes, got %+v", err)
+	}
+
+	expectedRequestString := `<?xml version="1.0" encoding="UTF-8"?>
+<soap-env:Envelope xmlns:soap-env="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap-env:Body>
+    <ns0:PurchaseReservedInstancesOffering xmlns:ns0="http://ec2.amazonaws.com/doc/2013-10-15/">
+      <ns0:reservedInstancesOfferingId>offering-1</ns0:reservedInstancesOfferingId>
+      <ns0:instanceCount>3</ns0:instanceCount>
+      <ns0:limitPrice>
+        <ns0:amount>1.5E2</ns0:amount>
+      </ns0:limitPrice>
+    </ns0:PurchaseReservedInstancesOffering>
+  </soap-env:Body>
+</soap-env:Envelope>`
+
+	if buf.String() != expectedRequestString {
+		t.Errorf("Unexpected XML request %s", buf.String())
+	}
+}
+
+func TestWebservice_NewRequest_ErrorPath_Datatypes(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/ec2.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	params := map[string]interface{}{
+		"PurchaseReservedInstancesOffering/reservedInstancesOfferingId": "offering-1",
+		"PurchaseReservedInstancesOffering/instanceCount":               int64(1) << 40,
+		"PurchaseReservedInstancesOffering/limitPrice/amount":           "cheap",
+	}
+
+	buf := new(bytes.Buffer)
+	err = testService.NewRequest("AmazonEC2", "PurchaseReservedInstancesOffering", params, buf)
+	validationErrors, ok := err.(xsd.ValidationErrors)
+	if !ok {
+		t.Fatalf("Expected xsd.ValidationErrors from testService.NewRequest, got %+v", err)
+	}
+
+	expectedErrors := xsd.ValidationErrors{
+		{Path: "PurchaseReservedInstancesOffering/instanceCount", Message: "value 1099511627776 is out of the range of xs:int"},
+		{Path: "PurchaseReservedInstancesOffering/limitPrice/amount", Message: `value "cheap" is not a valid xs:double`},
+	}
+	if !reflect.DeepEqual(validationErrors, expectedErrors) {
+		t.Errorf("Unexpected validation errors %+v", validationErrors)
+	}
+}
+
+type testZone struct {
+	region string
+	zone   string
+}
+
+func TestWebservice_NewRequest_HappyPath_Converters(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/ec2.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	xsd.RegisterConverter(testZone{}, func(name string, v interface{}) (string, error) {
+		z := v.(testZone)
+		return z.region + z.zone, nil
+	})
+
+	startTime := time.Date(2019, time.March, 4, 5, 6, 7, 0, time.UTC)
+	params := struct {
+		StartTime        time.Time  `xml:"startTime"`
+		EndTime          *time.Time `xml:"endTime"`
+		AvailabilityZone testZone   `xml:"availabilityZone"`
+		MaxResults       *big.Int   `xml:"maxResults"`
+	}{
+		StartTime:        startTime,
+		EndTime:          &startTime,
+		AvailabilityZone: testZone{region: "us-east-1", zone: "a"},
+		MaxResults:       new(big.Int).Lsh(big.NewInt(1), 70),
+	}
+
+	buf := new(bytes.Buffer)
+	err = testService.NewRequest("AmazonEC2", "DescribeSpotPriceHistory", params, buf)
+	if err != nil {
+		t.Errorf("Expected nil error from testService.NewRequest for converters, got %+v", err)
+	}
+
+	expectedRequestString := `<?xml version="1.0" encoding="UTF-8"?>
+<soap-env:Envelope xmlns:soap-env="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap-env:Body>
+    <ns0:DescribeSpotPriceHistory xmlns:ns0="http://ec2.amazonaws.com/doc/2013-10-15/">
+      <ns0:startTime>2019-03-04T05:06:07Z</ns0:startTime>
+      <ns0:endTime>2019-03-04T05:06:07Z</ns0:endTime>
+      <ns0:availabilityZone>us-east-1a</ns0:availabilityZone>
+      <ns0:maxResults>1180591620717411303424</ns0:maxResults>
+    </ns0:DescribeSpotPriceHistory>
+  </soap-env:Body>
+</soap-env:Envelope>`
+
+	if buf.String() != expectedRequestString {
+		t.Errorf("Unexpected XML request %s", buf.String())
+	}
+}
+
+func TestWebservice_NewRequest_HappyPath_Facets(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/catalog.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	params := map[string]interface{}{
+		"ReserveStock/sku":        "ABC-1234",
+		"ReserveStock/quantity":   11,
+		"ReserveStock/limitPrice": 1234.5,
+		"ReserveStock/currency":   "EUR",
+		"ReserveStock/note":       "  handle\twith   care ",
+	}
+
+	buf := new(bytes.Buffer)
+	err = testService.NewRequest("CatalogService", "ReserveStock", params, buf)
+	if err != nil {
+		t.Errorf("Expected nil error from testService.NewRequest for facets, got %+v", err)
+	}
+
+	expectedRequestString := `<?xml version="1.0" encoding="UTF-8"?>
+<soap-env:Envelope xmlns:soap-env="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap-env:Body>
+    <ns0:ReserveStock xmlns:ns0="http://example.com/catalog">
+      <sku>ABC-1234</sku>
+      <quantity>11</quantity>
+      <limitPrice>1234.5</limitPrice>
+      <currency>EUR</currency>
+      <note>handle with care</note>
+    </ns0:ReserveStock>
+  </soap-env:Body>
+</soap-env:Envelope>`
+
+	if buf.String() != expectedRequestString {
+		t.Errorf("Unexpected XML request %s", buf.String())
+	}
+}
+
+func TestWebservice_NewRequest_ErrorPath_Facets(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/catalog.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	params := map[string]interface{}{
+		"ReserveStock/sku":        "abc-1234",
+		"ReserveStock/quantity":   10,
+		"ReserveStock/limitPrice": "12345.678",
+		"ReserveStock/currency":   "GBP",
+		"ReserveStock/note":       "please handle this one with care",
+	}
+
+	buf := new(bytes.Buffer)
+	err = testService.NewRequest("CatalogService", "ReserveStock", params, buf)
+	validationErrors, ok := err.(xsd.ValidationErrors)
+	if !ok {
+		t.Fatalf("Expected xsd.ValidationErrors from testService.NewRequest, got %+v", err)
+	}
+
+	expectedErrors := xsd.ValidationErrors{
+		{Path: "ReserveStock/sku", Message: `value "abc-1234" violates the pattern facet "[A-Z]{3}-\\d{4}"`},
+		{Path: "ReserveStock/quantity", Message: `value "10" violates the minExclusive facet 10`},
+		{Path: "ReserveStock/limitPrice", Message: `value "12345.678" violates the totalDigits facet 6`},
+		{Path: "ReserveStock/currency", Message: `value "GBP" violates the enumeration facet ["USD" "EUR"]`},
+		{Path: "ReserveStock/note", Message: `value "please handle this one with care" violates the maxLength facet 20`},
+	}
+	if !reflect.DeepEqual(validationErrors, expectedErrors) {
+		t.Errorf("Unexpected validation errors %+v", validationErrors)
+	}
+}
+
+func TestWebservice_NewRequest_HappyPath_ListAndUnion(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/catalog.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	params := map[string]interface{}{
+		"ReserveStock/@channels":  []string{"web", "store"},
+		"ReserveStock/sku":        "ABC-1234",
+		"ReserveStock/quantity":   20,
+		"ReserveStock/limitPrice": "10.00",
+		"ReserveStock/currency":   "USD",
+		"ReserveStock/warehouses": []int{3, 1, 2},
+		"ReserveStock/backorder":  "all",
+	}
+
+	buf := new(bytes.Buffer)
+	err = testService.NewRequest("CatalogService", "ReserveStock", params, buf)
+	if err != nil {
+		t.Errorf("Expected nil error from testService.NewRequest for list and union types, got %+v", err)
+	}
+
+	expectedRequestString := `<?xml version="1.0" encoding="UTF-8"?>
+<soap-env:Envelope xmlns:soap-env="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap-env:Body>
+    <ns0:ReserveStock xmlns:ns0="http://example.com/catalog" channels="web store">
+      <sku>ABC-1234</sku>
+      <quantity>20</quantity>
+      <limitPrice>10.00</limitPrice>
+      <currency>USD</currency>
+      <warehouses>3 1 2</warehouses>
+      <backorder>all</backorder>
+    </ns0:ReserveStock>
+  </soap-env:Body>
+</soap-env:Envelope>`
+
+	if buf.String() != expectedRequestString {
+		t.Errorf("Unexpected XML request %s", buf.String())
+	}
+}
+
+func TestWebservice_NewRequest_ErrorPath_ListAndUnion(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/catalog.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	params := map[string]interface{}{
+		"ReserveStock/sku":        "ABC-1234",
+		"ReserveStock/quantity":   20,
+		"ReserveStock/limitPrice": "10.00",
+		"ReserveStock/currency":   "USD",
+		"ReserveStock/warehouses": []int{1, 2, 3, 4},
+		"ReserveStock/backorder":  "some",
+	}
+
+	buf := new(bytes.Buffer)
+	err = testService.NewRequest("CatalogService", "ReserveStock", params, buf)
+	validationErrors, ok := err.(xsd.ValidationErrors)
+	if !ok {
+		t.Fatalf("Expected xsd.ValidationErrors from testService.NewRequest, got %+v", err)
+	}
+
+	expectedErrors := xsd.ValidationErrors{
+		{Path: "ReserveStock/warehouses", Message: `value "1 2 3 4" violates the maxLength facet 3`},
+		{Path: "ReserveStock/backorder", Message: `value "some" is not valid for any member type of the union ["tns:QuantityType" "anonymous simpleType"]`},
+	}
+	if !reflect.DeepEqual(validationErrors, expectedErrors) {
+		t.Errorf("Unexpected validation errors %+v", validationErrors)
+	}
+}
+
+func TestWebservice_NewRequest_HappyPath_ElementRef(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/catalog.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	params := map[string]interface{}{
+		"GetItems/filter/category": "pens",
+		"GetItems/trace":           []string{"abc", "def"},
+	}
+
+	buf := new(bytes.Buffer)
+	err = testService.NewRequest("CatalogService", "GetItems", params, buf)
+	if err != nil {
+		t.Errorf("Expected nil error from testService.NewRequest for element refs, got %+v", err)
+	}
+
+	expectedRequestString := `<?xml version="1.0" encoding="UTF-8"?>
+<soap-env:Envelope xmlns:soap-env="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap-env:Body>
+    <ns0:GetItems xmlns:ns0="http://example.com/catalog">
+      <filter>
+        <category>pens</category>
+      </filter>
+      <ns1:trace xmlns:ns1="http://example.com/common">abc</ns1:trace>
+      <ns1:trace xmlns:ns1="http://example.com/common">def</ns1:trace>
+    </ns0:GetItems>
+  </soap-env:Body>
+</soap-env:Envelope>`
+
+	if buf.String() != expectedRequestString {
+		t.Errorf("Unexpected XML request %s", buf.String())
+	}
+
+	err = testService.NewRequest("CatalogService", "GetItems", map[string]interface{}{
+		"GetItems/filter/category": "pens",
+		"GetItems/trace":           []string{"abc", "def", "ghi"},
+	}, new(bytes.Buffer))
+	validationErrors, ok := err.(xsd.ValidationErrors)
+	if !ok {
+		t.Fatalf("Expected xsd.ValidationErrors from testService.NewRequest, got %+v", err)
+	}
+
+	expectedErrors := xsd.ValidationErrors{
+		{Path: "GetItems/trace", Message: "element 'trace' exceeds maxOccurs 2"},
+	}
+	if !reflect.DeepEqual(validationErrors, expectedErrors) {
+		t.Errorf("Unexpected validation errors %+v", validationErrors)
+	}
+}
+
+type testEqualsPredicate struct {
+	Field string `xml:"field"`
+	Value string `xml:"value"`
+}
+
+func (testEqualsPredicate) XSIType() string {
+	return "EqualsPredicateType"
+}
+
+func TestWebservice_NewRequest_HappyPath_XSIType(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/catalog.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	params := map[string]interface{}{
+		"GetItems/filter/category": "pens",
+		"GetItems/predicate": []map[string]interface{}{
+			{"@xsi:type": "EqualsPredicateType", "field": "color", "value": "red"},
+			{"@xsi:type": "{http://example.com/common}RangePredicateType", "@negate": true, "field": "size", "min": 1, "max": 5},
+		},
+	}
+
+	buf := new(bytes.Buffer)
+	err = testService.NewRequest("CatalogService", "GetItems", params, buf)
+	if err != nil {
+		t.Errorf("Expected nil error from testService.NewRequest for xsi:type, got %+v", err)
+	}
+
+	expectedRequestString := `<?xml version="1.0" encoding="UTF-8"?>
+<soap-env:Envelope xmlns:soap-env="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap-env:Body>
+    <ns0:GetItems xmlns:ns0="http://example.com/catalog">
+      <filter>
+        <category>pens</category>
+      </filter>
+      <predicate xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:type="ns0:EqualsPredicateType">
+        <field>color</field>
+        <value>red</value>
+      </predicate>
+      <predicate xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xmlns:ns1="http://example.com/common" xsi:type="ns1:RangePredicateType" negate="true">
+        <field>size</field>
+        <ns1:min>1</ns1:min>
+        <ns1:max>5</ns1:max>
+      </predicate>
+    </ns0:GetItems>
+  </soap-env:Body>
+</soap-env:Envelope>`
+
+	if buf.String() != expectedRequestString {
+		t.Errorf("Unexpected XML request %s", buf.String())
+	}
+
+	type getItems struct {
+		Category   string        `xml:"filter>category"`
+		Predicates []interface{} `xml:"predicate"`
+	}
+
+	buf = new(bytes.Buffer)
+	err = testService.NewRequest("CatalogService", "GetItems", getItems{
+		Category:   "pens",
+		Predicates: []interface{}{testEqualsPredicate{Field: "color", Value: "red"}},
+	}, buf)
+	if err != nil {
+		t.Errorf("Expected nil error from testService.NewRequest for a typed struct, got %+v", err)
+	}
+
+	expectedRequestString = `<?xml version="1.0" encoding="UTF-8"?>
+<soap-env:Envelope xmlns:soap-env="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap-env:Body>
+    <ns0:GetItems xmlns:ns0="http://example.com/catalog">
+      <filter>
+        <category>pens</category>
+      </filter>
+      <predicate xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:type="ns0:EqualsPredicateType">
+        <field>color</field>
+        <value>red</value>
+      </predicate>
+    </ns0:GetItems>
+  </soap-env:Body>
+</soap-env:Envelope>`
+
+	if buf.String() != expectedRequestString {
+		t.Errorf("Unexpected XML request %s", buf.String())
+	}
+}
+
+func TestWebservice_NewRequest_ErrorPath_XSIType(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/catalog.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	params := map[string]interface{}{
+		"GetItems/filter/category":     "pens",
+		"GetItems/filter/@xsi:type":    "FilterType",
+		"GetItems/predicate/@xsi:type": []string{"PriceType", "PredicateType", "MissingType"},
+		"GetItems/predicate/field":     []string{"color", "size", "weight", "height"},
+	}
+
+	err = testService.NewRequest("CatalogService", "GetItems", params, new(bytes.Buffer))
+	validationErrors, ok := err.(xsd.ValidationErrors)
+	if !ok {
+		t.Fatalf("Expected xsd.ValidationErrors from testService.NewRequest, got %+v", err)
+	}
+
+	expectedErrors := xsd.ValidationErrors{
+		{Path: "GetItems/predicate/@xsi:type", Message: "type '{http://example.com/catalog}PriceType' is not derived from the type '{http://example.com/catalog}PredicateType' of element 'predicate'"},
+		{Path: "GetItems/predicate/@xsi:type", Message: "type '{http://example.com/catalog}PredicateType' is abstract"},
+		{Path: "GetItems/predicate/@xsi:type", Message: "type '{http://example.com/catalog}MissingType' is not defined by the schema"},
+		{Path: "GetItems/predicate", Message: "type '{http://example.com/catalog}PredicateType' of element 'predicate' is abstract, a derived type has to be submitted at 'GetItems/predicate/@xsi:type'"},
+	}
+	if !reflect.DeepEqual(validationErrors, expectedErrors) {
+		t.Errorf("Unexpected validation errors %+v", validationErrors)
+	}
+}
+
+func TestWebservice_NewRequest_HappyPath_SimpleContent(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/catalog.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	params := map[string]interface{}{
+		"PriceItem/sku":             "PEN-0001",
+		"PriceItem/price":           12.5,
+		"PriceItem/price/@currency": "EUR",
+		"PriceItem/cap":             "999.99",
+		"PriceItem/code":            "PEN-0002",
+		"PriceItem/note/text":       "Best seller",
+		"PriceItem/note/@lang":      "en",
+	}
+
+	buf := new(bytes.Buffer)
+	err = testService.NewRequest("CatalogService", "PriceItem", params, buf)
+	if err != nil {
+		t.Errorf("Expected nil error from testService.NewRequest for simple content, got %+v", err)
+	}
+
+	expectedRequestString := `<?xml version="1.0" encoding="UTF-8"?>
+<soap-env:Envelope xmlns:soap-env="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap-env:Body>
+    <ns0:PriceItem xmlns:ns0="http://example.com/catalog">
+      <sku>PEN-0001</sku>
+      <price currency="EUR">12.5</price>
+      <cap currency="USD">999.99</cap>
+      <code scheme="internal">PEN-0002</code>
+      <note lang="en">
+        <text>Best seller</text>
+      </note>
+    </ns0:PriceItem>
+  </soap-env:Body>
+</soap-env:Envelope>`
+
+	if buf.String() != expectedRequestString {
+		t.Errorf("Unexpected XML request %s", buf.String())
+	}
+}
+
+func TestWebservice_NewRequest_ErrorPath_SimpleContent(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/catalog.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	params := map[string]interface{}{
+		"PriceItem/sku":             "PEN-0001",
+		"PriceItem/price/@currency": "GBP",
+		"PriceItem/cap":             1000.5,
+		"PriceItem/cap/@currency":   "EUR",
+		"PriceItem/code":            "pen",
+		"PriceItem/note/text":       "Best seller",
+		"PriceItem/note/@internal":  true,
+	}
+
+	err = testService.NewRequest("CatalogService", "PriceItem", params, new(bytes.Buffer))
+	validationErrors, ok := err.(xsd.ValidationErrors)
+	if !ok {
+		t.Fatalf("Expected xsd.ValidationErrors from testService.NewRequest, got %+v", err)
+	}
+
+	expectedErrors := xsd.ValidationErrors{
+		{Path: "PriceItem/price/@currency", Message: `value "GBP" violates the enumeration facet ["USD" "EUR"]`},
+		{Path: "PriceItem/price", Message: "did not find data 'PriceItem/price' in path"},
+		{Path: "PriceItem/cap/@currency", Message: `attribute 'currency' has the fixed value "USD", got "EUR"`},
+		{Path: "PriceItem/cap", Message: `value "1000.5" violates the maxInclusive facet 1000`},
+		{Path: "PriceItem/code", Message: `value "pen" violates the pattern facet "[A-Z]{3}-\\d{4}"`},
+		{Path: "PriceItem/note/@internal", Message: "attribute 'internal' is prohibited"},
+	}
+	if !reflect.DeepEqual(validationErrors, expectedErrors) {
+		t.Errorf("Unexpected validation errors %+v", validationErrors)
+	}
+
+	err = testService.NewRequest("CatalogService", "PriceItem", map[string]interface{}{
+		"PriceItem/sku":             "PEN-0001",
+		"PriceItem/price":           12.5,
+		"PriceItem/price/@currency": "EUR",
+		"PriceItem/note/text":       "Best seller",
+		"PriceItem/note/author":     "Jo",
+	}, new(bytes.Buffer), WithStrictParams(true))
+	if _, ok := err.(xsd.UnknownParamsError); !ok {
+		t.Errorf("Expected xsd.UnknownParamsError for an element removed by a restriction, got %+v", err)
+	}
+}
+
+func TestWebservice_NewRequest_HappyPath_QualifiedAttributes(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/catalog.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	// country and certified are global attributes, batch is qualified by attributeFormDefault and verifiedBy by its
+	// form
+	params := map[string]interface{}{
+		"PriceItem/sku":                "PEN-0001",
+		"PriceItem/price":              12.5,
+		"PriceItem/price/@currency":    "EUR",
+		"PriceItem/origin":             "Mexico",
+		"PriceItem/origin/@country":    "MX",
+		"PriceItem/origin/@batch":      7,
+		"PriceItem/origin/@verifiedBy": "qa",
+	}
+
+	buf := new(bytes.Buffer)
+	err = testService.NewRequest("CatalogService", "PriceItem", params, buf)
+	if err != nil {
+		t.Errorf("Expected nil error from testService.NewRequest for qualified attributes, got %+v", err)
+	}
+
+	expectedRequestString := `<?xml version="1.0" encoding="UTF-8"?>
+<soap-env:Envelope xmlns:soap-env="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap-env:Body>
+    <ns0:PriceItem xmlns:ns0="http://example.com/catalog">
+      <sku>PEN-0001</sku>
+      <price currency="EUR">12.5</price>
+      <origin xmlns:ns1="http://example.com/common" ns1:country="MX" ns1:certified="false" ns1:batch="7" ns0:verifiedBy="qa">Mexico</origin>
+    </ns0:PriceItem>
+  </soap-env:Body>
+</soap-env:Envelope>`
+
+	if buf.String() != expectedRequestString {
+		t.Errorf("Unexpected XML request %s", buf.String())
+	}
+}
+
+func TestWebservice_NewRequest_ErrorPath_AttributeRefs(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/catalog.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	// The use of country is given by its reference, the type of certified by its global declaration
+	params := map[string]interface{}{
+		"PriceItem/sku":               "PEN-0001",
+		"PriceItem/price":             12.5,
+		"PriceItem/price/@currency":   "EUR",
+		"PriceItem/origin":            "Mexico",
+		"PriceItem/origin/@certified": "maybe",
+	}
+
+	err = testService.NewRequest("CatalogService", "PriceItem", params, new(bytes.Buffer))
+	validationErrors, ok := err.(xsd.ValidationErrors)
+	if !ok {
+		t.Fatalf("Expected xsd.ValidationErrors from testService.NewRequest, got %+v", err)
+	}
+
+	expectedErrors := xsd.ValidationErrors{
+		{Path: "PriceItem/origin/@country", Message: "required attribute 'country' is missing"},
+		{Path: "PriceItem/origin/@certified", Message: `value "maybe" is not a valid xs:boolean`},
+	}
+	if !reflect.DeepEqual(validationErrors, expectedErrors) {
+		t.Errorf("Unexpected validation errors %+v", validationErrors)
+	}
+}
+
+func TestWebservice_NewRequest_HappyPath_Nil(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/catalog.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	params := map[string]interface{}{
+		"UpdateItem/id":          7,
+		"UpdateItem/name":        "Pen",
+		"UpdateItem/description": Nil,
+	}
+
+	buf := new(bytes.Buffer)
+	err = testService.NewRequest("CatalogService", "UpdateItem", params, buf)
+	if err != nil {
+		t.Errorf("Expected nil error from testService.NewRequest for Nil, got %+v", err)
+	}
+
+	expectedRequestString := `<?xml version="1.0" encoding="UTF-8"?>
+<soap-env:Envelope xmlns:soap-env="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap-env:Body>
+    <ns0:UpdateItem xmlns:ns0="http://example.com/catalog">
+      <id>7</id>
+      <name>Pen</name>
+      <description xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:nil="true"></description>
+    </ns0:UpdateItem>
+  </soap-env:Body>
+</soap-env:Envelope>`
+
+	if buf.String() != expectedRequestString {
+		t.Errorf("Unexpected XML request %s", buf.String())
+	}
+
+	type updateItem struct {
+		ID          int     `xml:"id"`
+		Name        string  `xml:"name"`
+		Description *string `xml:"description"`
+		Comment     *string `xml:"comment,omitempty"`
+	}
+
+	buf = new(bytes.Buffer)
+	err = testService.NewRequest("CatalogService", "UpdateItem", updateItem{ID: 7, Name: "Pen"}, buf)
+	if err != nil {
+		t.Errorf("Expected nil error from testService.NewRequest for a nil pointer, got %+v", err)
+	}
+
+	if buf.String() != expectedRequestString {
+		t.Errorf("Unexpected XML request %s", buf.String())
+	}
+}
+
+func TestWebservice_NewRequest_ErrorPath_Nil(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/catalog.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	params := map[string]interface{}{
+		"UpdateItem/id":      7,
+		"UpdateItem/name":    Nil,
+		"UpdateItem/comment": Nil,
+	}
+
+	err = testService.NewRequest("CatalogService", "UpdateItem", params, new(bytes.Buffer))
+	validationErrors, ok := err.(xsd.ValidationErrors)
+	if !ok {
+		t.Fatalf("Expected xsd.ValidationErrors from testService.NewRequest, got %+v", err)
+	}
+
+	expectedErrors := xsd.ValidationErrors{
+		{Path: "UpdateItem/name", Message: "element 'name' is not nillable"},
+		{Path: "UpdateItem/comment", Message: "element 'comment' is not nillable"},
+	}
+	if !reflect.DeepEqual(validationErrors, expectedErrors) {
+		t.Errorf("Unexpected validation errors %+v", validationErrors)
+	}
+}
+
+func TestWebservice_Do_HappyPath_Ports(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/catalog.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockResponseString := `<?xml version="1.0" encoding="utf-8"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+	<soap:Body>
+		<GetItemsResponse xmlns="http://example.com/catalog"/>
+	</soap:Body>
+</soap:Envelope>`
+
+	var requests []string
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+	mockClient.EXPECT().Do(gomock.Any()).DoAndReturn(func(req *http.Request) (*http.Response, error) {
+		requests = append(requests, req.URL.String()+" "+req.Header.Get("Content-Type")+" "+req.Header.Get("SOAPAction"))
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer([]byte(mockResponseString)))}, nil
+	}).Times(3)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	if testService.services["CatalogService"] == nil || testService.services["InventoryService"] == nil {
+		t.Fatalf("Expected every service of the WSDL to be added, got %+v", testService.services)
+	}
+
+	params := map[string]interface{}{
+		"GetItems/filter/category": "pens",
+	}
+
+	err = testService.Do("CatalogService", "GetItems", &struct{}{}, params)
+	if err != nil {
+		t.Errorf("Expected nil error from testService.Do for the default port, got %+v", err)
+	}
+
+	err = testService.Do("CatalogService", "GetItems", &struct{}{}, params, WithPort("CatalogSoap12Port"))
+	if err != nil {
+		t.Errorf("Expected nil error from testService.Do for the SOAP 1.2 port, got %+v", err)
+	}
+
+	err = testService.Do("InventoryService", "ReserveStock", &struct{}{}, map[string]interface{}{
+		"ReserveStock/sku":        "PEN-0001",
+		"ReserveStock/quantity":   20,
+		"ReserveStock/limitPrice": 1.5,
+		"ReserveStock/currency":   "USD",
+	})
+	if err != nil {
+		t.Errorf("Expected nil error from testService.Do for the second service, got %+v", err)
+	}
+
+	expectedRequests := []string{
+		`http://example.com/catalog text/xml; charset=utf-8 "http://example.com/catalog/GetItems"`,
+		`http://example.com/catalog/soap12 application/soap+xml; charset=utf-8; action="http://example.com/catalog/GetItems" `,
+		`http://example.com/inventory text/xml; charset=utf-8 "http://example.com/inventory/ReserveStock"`,
+	}
+	if !reflect.DeepEqual(requests, expectedRequests) {
+		t.Errorf("Unexpected requests %q", requests)
+	}
+}
+
+func TestWebservice_Do_ErrorPath_Ports(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/catalog.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	err = testService.Do("CatalogService", "GetItems", &struct{}{}, map[string]interface{}{}, WithPort("MissingPort"))
+	if err == nil || err.Error() != "did not find port 'MissingPort' in service 'CatalogService'" {
+		t.Errorf("Expected an error for an unknown port, got %+v", err)
+	}
+
+	err = testService.Do("CatalogService", "AddItems", &struct{}{}, map[string]interface{}{}, WithPort("CatalogSoap12Port"))
+	if err == nil || err.Error() != "did not find operation 'AddItems' in binding 'CatalogSoap12Binding'" {
+		t.Errorf("Expected an error for an operation missing in the binding of the port, got %+v", err)
+	}
+}
+
+func TestWebservice_NewRequest_HappyPath_RPC(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/orders.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	params := map[string]interface{}{
+		"PlaceOrder/customerId":     42,
+		"PlaceOrder/sku":            "PEN-0001",
+		"PlaceOrder/quantity":       3,
+		"PlaceOrder/address/street": "1 Main St",
+		"PlaceOrder/address/city":   "Springfield",
+	}
+
+	buf := new(bytes.Buffer)
+	err = testService.NewRequest("OrdersService", "PlaceOrder", params, buf)
+	if err != nil {
+		t.Errorf("Expected nil error from testService.NewRequest for an rpc operation, got %+v", err)
+	}
+
+	expectedRequestString := `<?xml version="1.0" encoding="UTF-8"?>
+<soap-env:Envelope xmlns:soap-env="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap-env:Body>
+    <ns0:PlaceOrder xmlns:ns0="http://example.com/orders/rpc">
+      <customerId>42</customerId>
+      <sku>PEN-0001</sku>
+      <quantity>3</quantity>
+      <address>
+        <ns1:street xmlns:ns1="http://example.com/orders">1 Main St</ns1:street>
+        <ns1:city xmlns:ns1="http://example.com/orders">Springfield</ns1:city>
+      </address>
+    </ns0:PlaceOrder>
+  </soap-env:Body>
+</soap-env:Envelope>`
+
+	if buf.String() != expectedRequestString {
+		t.Errorf("Unexpected XML request %s", buf.String())
+	}
+
+	type trackingOptions struct {
+		Carrier string `xml:"carrier"`
+	}
+	type trackOrder struct {
+		OrderID int             `xml:"orderId"`
+		Options trackingOptions `xml:"trackingOptions"`
+	}
+
+	buf = new(bytes.Buffer)
+	err = testService.NewRequest("OrdersService", "TrackOrder", trackOrder{OrderID: 7, Options: trackingOptions{Carrier: "UPS"}}, buf,
+		WithHeaderParams(map[string]interface{}{"locale": "en-US"}))
+	if err != nil {
+		t.Errorf("Expected nil error from testService.NewRequest for a document operation with several parts, got %+v", err)
+	}
+
+	expectedRequestString = `<?xml version="1.0" encoding="UTF-8"?>
+<soap-env:Envelope xmlns:soap-env="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap-env:Header>
+    <ns0:locale xmlns:ns0="http://example.com/orders">en-US</ns0:locale>
+  </soap-env:Header>
+  <soap-env:Body>
+    <ns0:orderId xmlns:ns0="http://example.com/orders">7</ns0:orderId>
+    <ns0:trackingOptions xmlns:ns0="http://example.com/orders">
+      <ns0:carrier>UPS</ns0:carrier>
+    </ns0:trackingOptions>
+  </soap-env:Body>
+</soap-env:Envelope>`
+
+	if buf.String() != expectedRequestString {
+		t.Errorf("Unexpected XML request %s", buf.String())
+	}
+}
+
+func TestWebservice_NewRequest_ErrorPath_RPC(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/orders.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	params := map[string]interface{}{
+		"PlaceOrder/customerId":     42,
+		"PlaceOrder/sku":            "pen",
+		"PlaceOrder/address/street": "1 Main St",
+	}
+
+	err = testService.NewRequest("OrdersService", "PlaceOrder", params, new(bytes.Buffer))
+	validationErrors, ok := err.(xsd.ValidationErrors)
+	if !ok {
+		t.Fatalf("Expected xsd.ValidationErrors from testService.NewRequest, got %+v", err)
+	}
+
+	expectedErrors := xsd.ValidationErrors{
+		{Path: "PlaceOrder/sku", Message: `value "pen" violates the pattern facet "[A-Z]{3}-[0-9]{4}"`},
+		{Path: "PlaceOrder/quantity", Message: "did not find data 'PlaceOrder/quantity' in path"},
+		{Path: "PlaceOrder/address/city", Message: "did not find data 'PlaceOrder/address/city' in path"},
+	}
+	if !reflect.DeepEqual(validationErrors, expectedErrors) {
+		t.Errorf("Unexpected validation errors %+v", validationErrors)
+	}
+}
+
+func TestWebservice_NewRequest_HappyPath_Encoded(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/orders.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	params := map[string]interface{}{
+		"SubmitOrder/customerId": 42,
+		"SubmitOrder/skus/item":  []string{"PEN-0001", "INK-0002"},
+		"SubmitOrder/addresses/item": []map[string]interface{}{
+			{"street": "1 Main St", "city": "Springfield"},
+		},
+	}
+
+	buf := new(bytes.Buffer)
+	err = testService.NewRequest("LegacyOrdersService", "SubmitOrder", params, buf)
+	if err != nil {
+		t.Errorf("Expected nil error from testService.NewRequest for an encoded operation, got %+v", err)
+	}
+
+	expectedRequestString := `<?xml version="1.0" encoding="UTF-8"?>
+<soap-env:Envelope xmlns:soap-env="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap-env:Body>
+    <ns0:SubmitOrder xmlns:ns0="urn:LegacyOrders" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xmlns:xsd="http://www.w3.org/2001/XMLSchema" xmlns:soapenc="http://schemas.xmlsoap.org/soap/encoding/" soap-env:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">
+      <customerId xsi:type="xsd:int">42</customerId>
+      <skus xsi:type="soapenc:Array" soapenc:arrayType="xsd:string[2]">
+        <item xsi:type="xsd:string">PEN-0001</item>
+        <item xsi:type="xsd:string">INK-0002</item>
+      </skus>
+      <addresses xmlns:ns1="http://example.com/legacy" xsi:type="soapenc:Array" soapenc:arrayType="ns1:AddressType[1]">
+        <item xsi:type="ns1:AddressType">
+          <street xsi:type="xsd:string">1 Main St</street>
+          <city xsi:type="xsd:string">Springfield</city>
+        </item>
+      </addresses>
+    </ns0:SubmitOrder>
+  </soap-env:Body>
+</soap-env:Envelope>`
+
+	if buf.String() != expectedRequestString {
+		t.Errorf("Unexpected XML request %s", buf.String())
+	}
+
+	type address struct {
+		Street string `xml:"street"`
+		City   string `xml:"city"`
+	}
+	type submitOrder struct {
+		CustomerID int       `xml:"customerId"`
+		Skus       []string  `xml:"skus>item"`
+		Addresses  []address `xml:"addresses>item"`
+	}
+
+	buf = new(bytes.Buffer)
+	err = testService.NewRequest("LegacyOrdersService", "SubmitOrder", submitOrder{
+		CustomerID: 42,
+		Skus:       []string{"PEN-0001", "INK-0002"},
+		Addresses:  []address{{Street: "1 Main St", City: "Springfield"}},
+	}, buf)
+	if err != nil {
+		t.Errorf("Expected nil error from testService.NewRequest for encoded struct params, got %+v", err)
+	}
+
+	if buf.String() != expectedRequestString {
+		t.Errorf("Unexpected XML request %s", buf.String())
+	}
+}
+
+func TestWebservice_Do_HappyPath_MultiRef(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/orders.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockResponseString := `<?xml version="1.0" encoding="utf-8"?>
+<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/" xmlns:soapenc="http://schemas.xmlsoap.org/soap/encoding/" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">
+	<soapenv:Body>
+		<ns1:SubmitOrderResponse soapenv:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/" xmlns:ns1="urn:LegacyOrders">
+			<return href="#id0"/>
+		</ns1:SubmitOrderResponse>
+		<multiRef id="id0" soapenc:root="0" xsi:type="ns2:OrderType" xmlns:ns2="http://example.com/legacy">
+			<id href="#id1"/>
+			<status xsi:type="xsd:string">accepted &amp; queued</status>
+			<address href="#id2"/>
+		</multiRef>
+		<multiRef id="id1" soapenc:root="0" xsi:type="xsd:int">1001</multiRef>
+		<multiRef id="id2" soapenc:root="0" xsi:type="ns3:AddressType" xmlns:ns3="http://example.com/legacy">
+			<street xsi:type="xsd:string">1 Main St</street>
+			<city xsi:type="xsd:string">Springfield</city>
+		</multiRef>
+	</soapenv:Body>
+</soapenv:Envelope>`
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer([]byte(mockResponseString)))}, nil).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	type submitOrderResponse struct {
+		Return struct {
+			ID      int    `xml:"id"`
+			Status  string `xml:"status"`
+			Address struct {
+				Street string `xml:"street"`
+				City   string `xml:"city"`
+			} `xml:"address"`
+		} `xml:"return"`
+	}
+
+	params := map[string]interface{}{
+		"SubmitOrder/customerId":            42,
+		"SubmitOrder/skus/item":             "PEN-0001",
+		"SubmitOrder/addresses/item/street": "1 Main St",
+		"SubmitOrder/addresses/item/city":   "Springfield",
+	}
+
+	resp := new(submitOrderResponse)
+	err = testService.Do("LegacyOrdersService", "SubmitOrder", resp, params)
+	if err != nil {
+		t.Errorf("Expected nil error from testService.Do, got %+v", err)
+	}
+
+	if resp.Return.ID != 1001 || resp.Return.Status != "accepted & queued" ||
+		resp.Return.Address.Street != "1 Main St" || resp.Return.Address.City != "Springfield" {
+		t.Errorf("Expected the multi-reference values to be decoded, got %+v", resp)
+	}
+}
+
+func TestWebservice_NewRequest_ErrorPath_Encoded(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/orders.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	mockClient.EXPECT().Do(gomock.Any()).Return(&http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServices("http://mocked.com/ws?WSDL")
+	if err != nil {
+		t.Errorf("Expected err to be nil")
+	}
+
+	params := map[string]interface{}{
+		"SubmitOrder/customerId": "forty-two",
+		"SubmitOrder/addresses/item": []map[string]interface{}{
+			{"street": "1 Main St", "city": "Springfield"},
+			{"street": "2 Main St"},
+		},
+	}
+
+	err = testService.NewRequest("LegacyOrdersService", "SubmitOrder", params, new(bytes.Buffer))
+	validationErrors, ok := err.(xsd.ValidationErrors)
+	if !ok {
+		t.Fatalf("Expected xsd.ValidationErrors from testService.NewRequest, got %+v", err)
+	}
+
+	expectedErrors := xsd.ValidationErrors{
+		{Path: "SubmitOrder/customerId", Message: `value "forty-two" is not a valid xs:int`},
+		{Path: "SubmitOrder/addresses/item/city", Message: "did not find data 'SubmitOrder/addresses/item/city' in path"},
+	}
+	if !reflect.DeepEqual(validationErrors, expectedErrors) {
+		t.Errorf("Unexpected validation errors %+v", validationErrors)
+	}
+}
@@ -1,14 +1,24 @@
 package goat
 
 import (
+	"bytes"
+	"context"
 	"github.com/sezzle/goat/client"
 	"github.com/sezzle/goat/wsdl"
+	"github.com/sezzle/goat/xsd"
+	"io"
+	"io/fs"
 	"net/http"
 )
 
+// Nil : Submitted as the value of an element declared nillable, the element is sent as xsi:nil="true" to clear it
+var Nil = xsd.Nil
+
 type Webservice struct {
-	services map[string]*wsdl.Definitions
-	client   client.Client
+	services     map[string]*wsdl.Definitions
+	client       client.Client
+	headerParams map[string]interface{}
+	strictParams bool
 }
 
 func NewWebservice() Webservice {
@@ -30,6 +40,18 @@ func (w *Webservice) UseHeader(header *http.Header) {
 	}
 }
 
+// UseHeaderParams : Sets the default SOAP header params, for example "RequestHeader/developerToken", which are sent
+// with every request whose binding operation declares a matching soap:header
+func (w *Webservice) UseHeaderParams(params map[string]interface{}) {
+	w.headerParams = params
+}
+
+// UseStrictParams : Fails every request with params that match no schema element with an xsd.UnknownParamsError.
+// Otherwise unknown params are only logged and the request is sent without them.
+func (w *Webservice) UseStrictParams(strict bool) {
+	w.strictParams = strict
+}
+
 func (w *Webservice) UseHistory() {
 	w.client.UseHistory = true
 	w.ClearHistory()
@@ -67,19 +89,70 @@ func (w *Webservice) GetHistory() (history *[]client.History) {
 }
 
 // AddServices : Given a submitted url or urls, unmarshal the wsdl definitions and store the unmarshalled definitions in memory
-// as "service". This will also fetch any additional imports on the WSDL
+// by the name of every service they declare. This will also fetch any additional imports on the WSDL
 func (w *Webservice) AddServices(urls ...string) error {
-	for _, u := range urls {
-		service := &wsdl.Definitions{
-			Aliases:           make(map[string]string),
-			ImportDefinitions: make(map[string]wsdl.Definitions),
-		}
-		err := service.GetService(&w.client, u)
+	return w.AddServicesContext(context.Background(), urls...)
+}
+
+// AddServicesContext : Like AddServices, but fetching the WSDLs and all of their imports is bound to ctx
+func (w *Webservice) AddServicesContext(ctx context.Context, urls ...string) error {
+	return w.loadServices(ctx, wsdl.NewClientLoader(&w.client), urls...)
+}
+
+// AddServicesFromFile : Like AddServices, but the WSDLs are read from the local files at paths. Relative imports are
+// read from files as well, relative to the directory of the document importing them.
+func (w *Webservice) AddServicesFromFile(paths ...string) error {
+	return w.loadServices(context.Background(), wsdl.NewFileLoader(&w.client), paths...)
+}
+
+// AddServicesFS : Like AddServices, but the WSDLs are read from fsys, for example an embed.FS, by their slash
+// separated paths. Relative imports are read from fsys as well, relative to the document importing them.
+func (w *Webservice) AddServicesFS(fsys fs.FS, paths ...string) error {
+	return w.loadServices(context.Background(), wsdl.NewFSLoader(fsys, &w.client), paths...)
+}
+
+// AddServicesFromReader : Like AddServices, but the WSDL is read from r. Its imports are fetched by their URLs.
+func (w *Webservice) AddServicesFromReader(r io.Reader) error {
+	service := newDefinitions()
+	err := service.ReadServiceContext(context.Background(), wsdl.NewClientLoader(&w.client), r)
+	if err != nil {
+		return err
+	}
+
+	w.addDefinitions(service)
+	return nil
+}
+
+// AddServicesFromBytes : Like AddServicesFromReader, but the WSDL is read from b
+func (w *Webservice) AddServicesFromBytes(b []byte) error {
+	return w.AddServicesFromReader(bytes.NewReader(b))
+}
+
+// loadServices loads the WSDLs at locations by loader, and adds every service they declare
+func (w *Webservice) loadServices(ctx context.Context, loader wsdl.Loader, locations ...string) error {
+	for _, l := range locations {
+		service := newDefinitions()
+		err := service.LoadServiceContext(ctx, loader, l)
 		if err != nil {
 			return err
 		}
-		w.services[service.Service.Name] = service
+
+		w.addDefinitions(service)
 	}
 
 	return nil
 }
+
+// addDefinitions stores the definitions by the name of every service they declare
+func (w *Webservice) addDefinitions(service *wsdl.Definitions) {
+	for _, s := range service.Services {
+		w.services[s.Name] = service
+	}
+}
+
+func newDefinitions() *wsdl.Definitions {
+	return &wsdl.Definitions{
+		Aliases:           make(map[string]string),
+		ImportDefinitions: make(map[string]wsdl.Definitions),
+	}
+}
@@ -2,13 +2,20 @@ package goat
 
 import (
 	"bytes"
+	"context"
 	"io/ioutil"
 	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"testing/fstest"
 
 	"github.com/golang/mock/gomock"
 	"github.com/sezzle/goat/client"
 	"github.com/sezzle/goat/wsdl"
+	"github.com/sezzle/goat/xsd"
 )
 
 func TestWebservice_AddServices_ErrorPath(t *testing.T) {
@@ -151,3 +158,381 @@ func TestWebservice_AddServices_HappyPath_MultipleImports(t *testing.T) {
 		t.Errorf("Expected err to be nil")
 	}
 }
+
+func TestWebservice_AddServicesContext_ErrorPath_Cancelled(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/ec2.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	mockClient.EXPECT().Do(gomock.Any()).DoAndReturn(func(req *http.Request) (*http.Response, error) {
+		if req.Context() != ctx {
+			t.Errorf("Expected the request to carry the submitted context")
+		}
+
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(testWSDL))}, nil
+	}).Times(1)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServicesContext(ctx, "http://mocked.com/ws?WSDL")
+	if err != context.Canceled {
+		t.Errorf("Expected context.Canceled from testService.AddServicesContext, got %+v", err)
+	}
+
+	if len(testService.services) != 0 {
+		t.Errorf("Expected no service to be added for a cancelled context")
+	}
+}
+
+func TestWebservice_AddServicesFromFile_HappyPath(t *testing.T) {
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err := testService.AddServicesFromFile("fixtures/catalog.wsdl")
+	if err != nil {
+		t.Errorf("Expected err to be nil, got %+v", err)
+	}
+
+	if testService.services["CatalogService"] == nil || testService.services["InventoryService"] == nil {
+		t.Fatalf("Expected every service of the WSDL to be added, got %+v", testService.services)
+	}
+
+	err = testService.NewRequest("CatalogService", "GetItems", map[string]interface{}{
+		"GetItems/filter/category": "pens",
+	}, new(bytes.Buffer))
+	if err != nil {
+		t.Errorf("Expected nil error from testService.NewRequest, got %+v", err)
+	}
+}
+
+func TestWebservice_AddServicesFromFile_HappyPath_RelativeImport(t *testing.T) {
+	operations, err := ioutil.ReadFile("fixtures/chromedata_operations.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	typeDefinitions, err := ioutil.ReadFile("fixtures/chromedata_types.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	dir := t.TempDir()
+	err = os.Mkdir(filepath.Join(dir, "types"), 0755)
+	if err != nil {
+		t.Fatalf("Error creating directory: %+v", err)
+	}
+
+	operations = bytes.Replace(operations, []byte("https://example.com/chromedata/ws?wsdl=Chrome.wsdl"), []byte("types/Chrome.wsdl"), 1)
+	err = ioutil.WriteFile(filepath.Join(dir, "operations.wsdl"), operations, 0644)
+	if err != nil {
+		t.Fatalf("Error writing wsdl file: %+v", err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "types", "Chrome.wsdl"), typeDefinitions, 0644)
+	if err != nil {
+		t.Fatalf("Error writing wsdl file: %+v", err)
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServicesFromFile(filepath.Join(dir, "operations.wsdl"))
+	if err != nil {
+		t.Errorf("Expected err to be nil, got %+v", err)
+	}
+
+	service := testService.services["Description7a"]
+	if service == nil || len(service.ImportDefinitions) != 1 {
+		t.Errorf("Expected the import to be read relative to the WSDL, got %+v", service)
+	}
+}
+
+func TestWebservice_AddServicesFromBytes_HappyPath(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/orders.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServicesFromBytes(testWSDL)
+	if err != nil {
+		t.Errorf("Expected err to be nil, got %+v", err)
+	}
+
+	if testService.services["OrdersService"] == nil || testService.services["LegacyOrdersService"] == nil {
+		t.Errorf("Expected every service of the WSDL to be added, got %+v", testService.services)
+	}
+
+	err = testService.AddServicesFromReader(strings.NewReader("<definitions/>"))
+	if err == nil {
+		t.Errorf("Expected an error for a WSDL without services")
+	}
+}
+
+func TestWebservice_AddServicesFS_HappyPath(t *testing.T) {
+	operations, err := ioutil.ReadFile("fixtures/chromedata_operations.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	typeDefinitions, err := ioutil.ReadFile("fixtures/chromedata_types.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	fsys := fstest.MapFS{
+		"wsdl/operations.wsdl": {Data: bytes.Replace(operations, []byte("https://example.com/chromedata/ws?wsdl=Chrome.wsdl"), []byte("../types/Chrome.wsdl"), 1)},
+		"types/Chrome.wsdl":    {Data: typeDefinitions},
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServicesFS(fsys, "wsdl/operations.wsdl")
+	if err != nil {
+		t.Errorf("Expected err to be nil, got %+v", err)
+	}
+
+	service := testService.services["Description7a"]
+	if service == nil || len(service.ImportDefinitions) != 1 {
+		t.Errorf("Expected the import to be read from the file system, got %+v", service)
+	}
+}
+
+func TestWebservice_AddServicesFS_ErrorPath(t *testing.T) {
+	operations, err := ioutil.ReadFile("fixtures/chromedata_operations.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	fsys := fstest.MapFS{
+		"operations.wsdl": {Data: bytes.Replace(operations, []byte("https://example.com/chromedata/ws?wsdl=Chrome.wsdl"), []byte("Chrome.wsdl"), 1)},
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServicesFS(fsys, "missing.wsdl")
+	if err == nil {
+		t.Errorf("Expected an error for a missing WSDL")
+	}
+
+	err = testService.AddServicesFS(fsys, "operations.wsdl")
+	if err == nil {
+		t.Errorf("Expected an error for a missing import")
+	}
+
+	if len(testService.services) != 0 {
+		t.Errorf("Expected no service to be added, got %+v", testService.services)
+	}
+}
+
+func TestWebservice_AddServicesFromFile_HappyPath_SchemaImports(t *testing.T) {
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err := testService.AddServicesFromFile("fixtures/shop.wsdl")
+	if err != nil {
+		t.Fatalf("Expected err to be nil, got %+v", err)
+	}
+
+	params := map[string]interface{}{
+		"PlaceOrder/order/sku":            "PEN-0001",
+		"PlaceOrder/order/price/amount":   1.5,
+		"PlaceOrder/order/price/currency": "USD",
+	}
+
+	buf := new(bytes.Buffer)
+	err = testService.NewRequest("ShopService", "PlaceOrder", params, buf)
+	if err != nil {
+		t.Errorf("Expected nil error from testService.NewRequest, got %+v", err)
+	}
+
+	expectedRequestString := `<?xml version="1.0" encoding="UTF-8"?>
+<soap-env:Envelope xmlns:soap-env="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap-env:Body>
+    <ns0:PlaceOrder xmlns:ns0="http://example.com/shop">
+      <ns0:order>
+        <ns0:sku>PEN-0001</ns0:sku>
+        <ns0:price>
+          <ns1:amount xmlns:ns1="http://example.com/common">1.5</ns1:amount>
+          <ns1:currency xmlns:ns1="http://example.com/common">USD</ns1:currency>
+        </ns0:price>
+      </ns0:order>
+    </ns0:PlaceOrder>
+  </soap-env:Body>
+</soap-env:Envelope>`
+
+	if buf.String() != expectedRequestString {
+		t.Errorf("Unexpected XML request %s", buf.String())
+	}
+
+	params = map[string]interface{}{
+		"PlaceOrder/order/sku":            "pen",
+		"PlaceOrder/order/price/amount":   1.5,
+		"PlaceOrder/order/price/currency": "GBP",
+	}
+
+	err = testService.NewRequest("ShopService", "PlaceOrder", params, new(bytes.Buffer))
+	validationErrors, ok := err.(xsd.ValidationErrors)
+	if !ok {
+		t.Fatalf("Expected xsd.ValidationErrors from testService.NewRequest, got %+v", err)
+	}
+
+	expectedErrors := xsd.ValidationErrors{
+		{Path: "PlaceOrder/order/sku", Message: `value "pen" violates the pattern facet "[A-Z]{3}-[0-9]{4}"`},
+		{Path: "PlaceOrder/order/price/currency", Message: `value "GBP" violates the enumeration facet ["USD" "EUR"]`},
+	}
+	if !reflect.DeepEqual(validationErrors, expectedErrors) {
+		t.Errorf("Unexpected validation errors %+v", validationErrors)
+	}
+}
+
+func TestWebservice_AddServices_HappyPath_RelativeImports(t *testing.T) {
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	documents := map[string]string{
+		"http://mocked.com/ws/shop.wsdl":                 "fixtures/shop.wsdl",
+		"http://mocked.com/ws/shop_types.xsd":            "fixtures/shop_types.xsd",
+		"http://mocked.com/ws/common/money.xsd":          "fixtures/common/money.xsd",
+		"http://mocked.com/ws/common/currency.xsd":       "fixtures/common/currency.xsd",
+		"http://mocked.com/chromedata/operations":        "fixtures/chromedata_operations.wsdl",
+		"http://mocked.com/chromedata/types/Chrome.wsdl": "fixtures/chromedata_types.wsdl",
+	}
+
+	var requested []string
+	mockClient.EXPECT().Do(gomock.Any()).DoAndReturn(func(req *http.Request) (*http.Response, error) {
+		requested = append(requested, req.URL.String())
+		data, err := ioutil.ReadFile(documents[req.URL.String()])
+		if err != nil {
+			return &http.Response{StatusCode: http.StatusNotFound, Body: ioutil.NopCloser(bytes.NewBuffer(nil))}, nil
+		}
+
+		if strings.HasSuffix(req.URL.Path, "operations") {
+			data = bytes.Replace(data, []byte("https://example.com/chromedata/ws?wsdl=Chrome.wsdl"), []byte("types/Chrome.wsdl"), 1)
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer(data))}, nil
+	}).Times(6)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err := testService.AddServices("http://mocked.com/ws/shop.wsdl", "http://mocked.com/chromedata/operations")
+	if err != nil {
+		t.Errorf("Expected err to be nil, got %+v", err)
+	}
+
+	expectedRequested := []string{
+		"http://mocked.com/ws/shop.wsdl",
+		"http://mocked.com/ws/shop_types.xsd",
+		"http://mocked.com/ws/common/money.xsd",
+		"http://mocked.com/ws/common/currency.xsd",
+		"http://mocked.com/chromedata/operations",
+		"http://mocked.com/chromedata/types/Chrome.wsdl",
+	}
+	if !reflect.DeepEqual(requested, expectedRequested) {
+		t.Errorf("Unexpected requests %q", requested)
+	}
+
+	service := testService.services["ShopService"]
+	if service == nil || len(service.Types.Schemas) != 2 {
+		t.Errorf("Expected the imported schema to be added to the schemas, got %+v", service)
+	}
+}
+
+func TestWebservice_AddServicesFS_ErrorPath_SchemaImports(t *testing.T) {
+	testWSDL, err := ioutil.ReadFile("fixtures/shop.wsdl")
+	if err != nil {
+		t.Errorf("Error reading wsdl file")
+	}
+
+	fsys := fstest.MapFS{
+		"shop.wsdl": {Data: testWSDL},
+	}
+
+	mockClientController := gomock.NewController(t)
+	defer mockClientController.Finish()
+
+	mockClient := client.NewMockHTTPClientDoer(mockClientController)
+
+	testService := Webservice{
+		services: map[string]*wsdl.Definitions{},
+		client:   client.Client{Client: mockClient},
+	}
+
+	err = testService.AddServicesFS(fsys, "shop.wsdl")
+	if err == nil {
+		t.Errorf("Expected an error for a missing included schema")
+	}
+
+	// A location above the root of the file system is reported with the location as given by the schema
+	fsys["shop.wsdl"] = &fstest.MapFile{Data: bytes.Replace(testWSDL, []byte(`schemaLocation="shop_types.xsd"`), []byte(`schemaLocation="../shop_types.xsd"`), 1)}
+	err = testService.AddServicesFS(fsys, "shop.wsdl")
+	if err == nil || !strings.Contains(err.Error(), "'../shop_types.xsd'") {
+		t.Errorf("Expected an error naming the included schema outside of the file system, got %+v", err)
+	}
+
+	if len(testService.services) != 0 {
+		t.Errorf("Expected no service to be added, got %+v", testService.services)
+	}
+}
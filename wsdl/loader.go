@@ -0,0 +1,146 @@
+package wsdl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/sezzle/sezzle-go-xml"
+
+	"github.com/sezzle/goat/client"
+)
+
+// Loader loads the documents of a WSDL: the definitions, and every document they import
+type Loader interface {
+	// Load decodes the document at location into v
+	Load(ctx context.Context, location string, v interface{}) error
+	// Resolve returns the location of ref, a location given by the document at base, or an error if ref can't be
+	// loaded from there
+	Resolve(base, ref string) (string, error)
+}
+
+// NewClientLoader : Returns a Loader which fetches documents from their URLs by client. Relative locations are
+// resolved against the URL of the document giving them.
+func NewClientLoader(client *client.Client) Loader {
+	return clientLoader{client: client}
+}
+
+type clientLoader struct {
+	client *client.Client
+}
+
+func (l clientLoader) Load(ctx context.Context, location string, v interface{}) error {
+	return l.client.MakeRequestContext(ctx, "GET", location, nil, nil, v)
+}
+
+func (l clientLoader) Resolve(base, ref string) (string, error) {
+	if base == "" {
+		return ref, nil
+	}
+
+	b, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+
+	r, err := url.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+
+	return b.ResolveReference(r).String(), nil
+}
+
+// NewFSLoader : Returns a Loader which reads documents from fsys by their slash separated paths, like those of an
+// embed.FS. Relative locations are resolved within fsys, documents given by an URL are fetched by client.
+func NewFSLoader(fsys fs.FS, client *client.Client) Loader {
+	return fsLoader{fsys: fsys, client: clientLoader{client: client}}
+}
+
+type fsLoader struct {
+	fsys   fs.FS
+	client clientLoader
+}
+
+func (l fsLoader) Load(ctx context.Context, location string, v interface{}) error {
+	if isURL(location) {
+		return l.client.Load(ctx, location, v)
+	}
+
+	f, err := l.fsys.Open(location)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return decodeDocument(ctx, f, v)
+}
+
+func (l fsLoader) Resolve(base, ref string) (string, error) {
+	if isURL(base) {
+		return l.client.Resolve(base, ref)
+	}
+	if isURL(ref) || base == "" {
+		return ref, nil
+	}
+
+	// A path leading above the root, like "../common.xsd" at the top, can't be opened
+	location := path.Join(path.Dir(base), ref)
+	if !fs.ValidPath(location) {
+		return "", fmt.Errorf("location '%s' given by '%s' is outside of the file system", ref, base)
+	}
+
+	return location, nil
+}
+
+// NewFileLoader : Returns a Loader which reads documents from the local file system by their paths. Relative
+// locations are resolved against the directory of the document giving them, documents given by an URL are fetched by
+// client.
+func NewFileLoader(client *client.Client) Loader {
+	return fileLoader{client: clientLoader{client: client}}
+}
+
+type fileLoader struct {
+	client clientLoader
+}
+
+func (l fileLoader) Load(ctx context.Context, location string, v interface{}) error {
+	if isURL(location) {
+		return l.client.Load(ctx, location, v)
+	}
+
+	f, err := os.Open(location)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return decodeDocument(ctx, f, v)
+}
+
+func (l fileLoader) Resolve(base, ref string) (string, error) {
+	if isURL(base) {
+		return l.client.Resolve(base, ref)
+	}
+	if isURL(ref) || base == "" || filepath.IsAbs(ref) {
+		return ref, nil
+	}
+
+	return filepath.Join(filepath.Dir(base), filepath.FromSlash(ref)), nil
+}
+
+// isURL reports whether location is an URL rather than a path
+func isURL(location string) bool {
+	return strings.Contains(location, "://")
+}
+
+// decodeDocument decodes the document read from r into v, which is aborted once ctx is done
+func decodeDocument(ctx context.Context, r io.Reader, v interface{}) error {
+	return xml.NewDecoder(client.NewContextReader(ctx, r)).Decode(v)
+}
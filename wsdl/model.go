@@ -17,13 +17,18 @@ type Type struct {
 }
 
 type Message struct {
-	Name string `xml:"name,attr"`
-	Part Part   `xml:"part"`
+	Name  string `xml:"name,attr"`
+	Parts []Part `xml:"part"`
+
+	// Part is the first part of the message, which is the only one of document/literal messages
+	Part Part `xml:"-"`
 }
 
+// Part is a part of a message, either a global element of a schema or a value of a schema type
 type Part struct {
 	Name    string `xml:"name,attr"`
 	Element string `xml:"element,attr"`
+	Type    string `xml:"type,attr"`
 }
 
 type PortType struct {
@@ -33,10 +38,10 @@ type PortType struct {
 }
 
 type PortTypeOperation struct {
-	Name   string                   `xml:"name,attr"`
-	Input  PortTypeOperationMessage `xml:"input"`
-	Output PortTypeOperationMessage `xml:"output"`
-	Fault  PortTypeOperationMessage `xml:"fault"`
+	Name   string                     `xml:"name,attr"`
+	Input  PortTypeOperationMessage   `xml:"input"`
+	Output PortTypeOperationMessage   `xml:"output"`
+	Faults []PortTypeOperationMessage `xml:"fault"`
 }
 
 type PortTypeOperationMessage struct {
@@ -62,28 +67,35 @@ type BindingOperation struct {
 	SoapOperation SoapOperation `xml:"operation"`
 	Input         SoapBodyIO    `xml:"input"`
 	Output        SoapBodyIO    `xml:"output"`
-	Fault         SoapBody      `xml:"fault>fault"`
+	Faults        []SoapBody    `xml:"fault>fault"`
 }
 
 type SoapOperation struct {
 	SoapAction string `xml:"soapAction,attr"`
+	Style      string `xml:"style,attr"` // Overrides the style of the binding for the operation
 }
 
 type SoapBodyIO struct {
-	Name       string   `xml:"name,attr"`
-	SoapHeader SoapBody `xml:"header"`
-	SoapBody   SoapBody `xml:"body"`
+	Name        string     `xml:"name,attr"`
+	SoapHeaders []SoapBody `xml:"header"`
+	SoapBody    SoapBody   `xml:"body"`
 }
 
 type SoapBody struct {
 	PortTypeOperationMessage
-	Part string `xml:"part,attr"`
-	Use  string `xml:"use,attr"`
+	Part          string `xml:"part,attr"`          // The part of a soap:header message
+	Parts         string `xml:"parts,attr"`         // The parts of the message in the body, all of them if empty
+	Use           string `xml:"use,attr"`           // literal or encoded
+	EncodingStyle string `xml:"encodingStyle,attr"` // The encoding of encoded messages, SOAP encoding by default
+	Namespace     string `xml:"namespace,attr"`     // The namespace of the operation element of rpc style operations
 }
 
 type Service struct {
-	Name string      `xml:"name,attr"`
-	Port ServicePort `xml:"port"`
+	Name  string        `xml:"name,attr"`
+	Ports []ServicePort `xml:"port"`
+
+	// Port is the first port of the service
+	Port ServicePort `xml:"-"`
 }
 
 type ServicePort struct {
@@ -0,0 +1,230 @@
+package wsdl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Port is a port of a service of the definitions. Requests are written by the binding of the port and sent to its
+// address.
+type Port struct {
+	defs *Definitions
+
+	Service string
+	ServicePort
+}
+
+// Port : Returns the port named port of the service named service. An empty service stands for the first service of
+// the definitions, an empty port for the default port of the service: the first one bound to SOAP 1.1, or its first
+// port if there is none.
+func (d *Definitions) Port(service, port string) (*Port, error) {
+	var svc *Service
+	for i := range d.Services {
+		if service == "" || d.Services[i].Name == service {
+			svc = &d.Services[i]
+			break
+		}
+	}
+
+	if svc == nil {
+		return nil, fmt.Errorf("did not find service '%s'", service)
+	}
+
+	if len(svc.Ports) == 0 {
+		return nil, fmt.Errorf("service '%s' has no ports", svc.Name)
+	}
+
+	if port != "" {
+		for _, sp := range svc.Ports {
+			if sp.Name == port {
+				return &Port{defs: d, Service: svc.Name, ServicePort: sp}, nil
+			}
+		}
+
+		return nil, fmt.Errorf("did not find port '%s' in service '%s'", port, svc.Name)
+	}
+
+	for _, sp := range svc.Ports {
+		p := &Port{defs: d, Service: svc.Name, ServicePort: sp}
+		if version, err := p.GetSoapVersion(); err == nil && version == Soap11 {
+			return p, nil
+		}
+	}
+
+	return &Port{defs: d, Service: svc.Name, ServicePort: svc.Ports[0]}, nil
+}
+
+// Location : Returns the address requests to the port are sent to
+func (p *Port) Location() string {
+	return p.Address.Location
+}
+
+// getBinding returns the binding referenced by the port, and the definitions it is declared in
+func (p *Port) getBinding() (Binding, *Definitions, error) {
+	d := p.defs
+	parts := strings.Split(p.Binding, ":")
+	switch len(parts) {
+	case 2:
+		if d.GetNamespace(parts[0]) != d.TargetNamespace {
+			imported, ok := d.ImportDefinitions[parts[0]]
+			if !ok {
+				return Binding{}, nil, fmt.Errorf("have '%s', want '%s' as target namespace", parts[0], d.TargetNamespace)
+			}
+			d = &imported
+		}
+
+		parts[0] = parts[1]
+		fallthrough
+	case 1:
+		for _, bnd := range d.Binding {
+			if bnd.Name == parts[0] {
+				return bnd, d, nil
+			}
+		}
+
+		return Binding{}, nil, fmt.Errorf("did not find binding '%s'", parts[0])
+	default:
+		return Binding{}, nil, fmt.Errorf("malformed binding information: '%s'", p.Binding)
+	}
+}
+
+func (p *Port) getOperations(operation string) (bndOp BindingOperation, ptOp PortTypeOperation, err error) {
+	var bnd Binding
+	var service *Definitions
+	bnd, service, err = p.getBinding()
+	if err != nil {
+		return
+	}
+
+	parts := strings.Split(bnd.Type, ":")
+	switch len(parts) {
+	case 2:
+		if service.GetNamespace(parts[0]) != service.TargetNamespace {
+			imported, ok := service.ImportDefinitions[parts[0]]
+			if !ok {
+				err = fmt.Errorf("cannot find '%s' namespace in binding %s", parts[0], bnd.Name)
+				return
+			}
+			service = &imported
+		}
+		parts[0] = parts[1]
+		fallthrough
+	case 1:
+		var portType *PortType
+		for i := range service.PortTypes {
+			if service.PortTypes[i].Name == parts[0] {
+				portType = &service.PortTypes[i]
+			}
+		}
+
+		if portType == nil {
+			err = fmt.Errorf("did not find porttype '%s' of binding '%s'", parts[0], bnd.Name)
+			return
+		}
+
+		var found bool
+		for _, ptOp = range portType.Operations {
+			found = ptOp.Name == operation
+			if found {
+				break
+			}
+		}
+
+		if !found {
+			err = fmt.Errorf("did not find porttype operation '%s' in binding '%s'", operation, bnd.Name)
+			return
+		}
+	default:
+		err = fmt.Errorf("malformed binding information '%s' in binding '%s'", bnd.Type, bnd.Name)
+		return
+	}
+
+	for _, bndOp = range bnd.Operations {
+		if bndOp.Name == operation {
+			return
+		}
+	}
+
+	err = fmt.Errorf("did not find operation '%s' in binding '%s'", operation, bnd.Name)
+	return
+}
+
+// GetSoapVersion : Returns the SOAP version of the binding used by the default port
+func (d *Definitions) GetSoapVersion() (SoapVersion, error) {
+	p, err := d.Port("", "")
+	if err != nil {
+		return SoapVersion{}, err
+	}
+
+	return p.GetSoapVersion()
+}
+
+// GetSoapVersion : Returns the SOAP version of the binding of the port
+func (p *Port) GetSoapVersion() (SoapVersion, error) {
+	bnd, _, err := p.getBinding()
+	if err != nil {
+		return SoapVersion{}, err
+	}
+
+	return bnd.SoapVersion()
+}
+
+// GetSoapAction : Returns the soapAction of the binding operation of the default port
+func (d *Definitions) GetSoapAction(operation string) (string, error) {
+	p, err := d.Port("", "")
+	if err != nil {
+		return "", err
+	}
+
+	return p.GetSoapAction(operation)
+}
+
+// GetSoapAction : Returns the soapAction of the binding operation
+func (p *Port) GetSoapAction(operation string) (string, error) {
+	bndOp, _, err := p.getOperations(operation)
+	if err != nil {
+		return "", err
+	}
+
+	return bndOp.SoapOperation.SoapAction, nil
+}
+
+// rpcStyle is the style of bindings whose body is an element named after the operation, which wraps the parts
+const rpcStyle = "rpc"
+
+// getStyle returns the style of the binding operation: its own, the one of the binding, or document by default
+func (p *Port) getStyle(bndOp BindingOperation) (string, error) {
+	if bndOp.SoapOperation.Style != "" {
+		return bndOp.SoapOperation.Style, nil
+	}
+
+	bnd, _, err := p.getBinding()
+	if err != nil {
+		return "", err
+	}
+
+	if bnd.SoapBinding.Style != "" {
+		return bnd.SoapBinding.Style, nil
+	}
+
+	return "document", nil
+}
+
+// encodedUse is the use of messages which are encoded by an encoding like SOAP encoding, rather than their schema
+const encodedUse = "encoded"
+
+// GetUse : Returns the use, literal or encoded, of the input and the output of the binding operation
+func (p *Port) GetUse(operation string) (input, output string, err error) {
+	bndOp, _, err := p.getOperations(operation)
+	if err != nil {
+		return "", "", err
+	}
+
+	return bndOp.Input.SoapBody.Use, bndOp.Output.SoapBody.Use, nil
+}
+
+// EncodedOutput : Reports whether the output of the binding operation is encoded, like by SOAP encoding
+func (p *Port) EncodedOutput(operation string) bool {
+	_, output, err := p.GetUse(operation)
+	return err == nil && output == encodedUse
+}
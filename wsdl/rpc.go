@@ -0,0 +1,49 @@
+package wsdl
+
+import (
+	"github.com/sezzle/goat/xsd"
+)
+
+// encodeRPC encodes the parts of an rpc style operation below an element named after the operation in namespace.
+// The parts are accessors named after them, which are unqualified.
+func encodeRPC(enc *xsd.Encoder, operation, namespace string, parts []messagePart, params map[string]interface{}) error {
+	end, err := enc.StartElement(namespace, operation)
+	if err != nil {
+		return err
+	}
+
+	for _, part := range parts {
+		if part.Element != "" {
+			err = part.schema.EncodeElement(part.element, enc, part.service.Types.Schemas, params, operation)
+		} else {
+			accessor := xsd.Element{Name: part.Name, Type: part.Type}
+			err = accessor.Encode(enc, part.service.Types.Schemas, partAliaser{part.service}, params, operation)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return end()
+}
+
+// partAliaser resolves the prefixes of the types of message parts by the namespaces declared by the definitions
+type partAliaser struct {
+	d *Definitions
+}
+
+func (a partAliaser) GetAlias(alias string) string {
+	return a.d.GetNamespace(alias)
+}
+
+func (a partAliaser) Namespace() string {
+	return ""
+}
+
+func (a partAliaser) QualifiesElement(string) bool {
+	return false
+}
+
+func (a partAliaser) QualifiesAttribute(string) bool {
+	return false
+}
@@ -0,0 +1,111 @@
+package wsdl
+
+import (
+	"context"
+	"log"
+
+	"github.com/sezzle/goat/xsd"
+)
+
+// schemaLoader loads the schemas imported and included by the schemas of a WSDL into its SchemaMap
+type schemaLoader struct {
+	loader  Loader
+	schemas xsd.SchemaMap
+
+	// loaded holds every location loaded so far, by the namespace it has been loaded into. A chameleon include can be
+	// loaded into several namespaces.
+	loaded map[string]map[string]bool
+}
+
+// addSchemas loads every schema imported or included by the schemas of the definitions at base, recursively, and
+// merges them into the SchemaMap of the definitions. Schema imports without schemaLocation are left to the schemas
+// already known.
+func (d *Definitions) addSchemas(ctx context.Context, loader Loader, base string) error {
+	if d.Types.Schemas == nil {
+		d.Types.Schemas = xsd.SchemaMap{}
+	}
+
+	l := &schemaLoader{loader: loader, schemas: d.Types.Schemas, loaded: map[string]map[string]bool{}}
+	for _, schema := range d.Types.Schemata {
+		err := l.addReferenced(ctx, schema, base)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addReferenced loads the schemas imported and included by schema, a document at base
+func (l *schemaLoader) addReferenced(ctx context.Context, schema xsd.Schema, base string) error {
+	for _, include := range schema.Includes {
+		if include.SchemaLocation == "" {
+			continue
+		}
+
+		location, err := l.loader.Resolve(base, include.SchemaLocation)
+		if err != nil {
+			return err
+		}
+
+		err = l.add(ctx, schema.TargetNamespace, location)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, imp := range schema.Imports {
+		if imp.SchemaLocation == "" {
+			continue
+		}
+
+		location, err := l.loader.Resolve(base, imp.SchemaLocation)
+		if err != nil {
+			return err
+		}
+
+		err = l.add(ctx, imp.Namespace, location)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// add loads the schema at location into namespace, unless it has been loaded into it already. The schema is included
+// by a schema of the same namespace, if there is one.
+func (l *schemaLoader) add(ctx context.Context, namespace, location string) error {
+	if l.loaded[location][namespace] {
+		return nil
+	}
+	if l.loaded[location] == nil {
+		l.loaded[location] = map[string]bool{}
+	}
+	l.loaded[location][namespace] = true
+
+	log.Printf("adding schema from '%s'", location)
+	var schema xsd.Schema
+	err := l.loader.Load(ctx, location, &schema)
+	if err != nil {
+		return err
+	}
+
+	schema.Adopt(namespace)
+
+	// The schemas referenced by this one are loaded first, so they are known in whichever order it is used
+	err = l.addReferenced(ctx, schema, location)
+	if err != nil {
+		return err
+	}
+
+	existing, ok := l.schemas[schema.TargetNamespace]
+	if !ok {
+		l.schemas[schema.TargetNamespace] = schema
+		return nil
+	}
+
+	existing.Include(schema)
+	l.schemas[schema.TargetNamespace] = existing
+	return nil
+}
@@ -0,0 +1,54 @@
+package wsdl
+
+import (
+	"fmt"
+)
+
+const (
+	soap11BindingNamespace = "http://schemas.xmlsoap.org/wsdl/soap/"
+	soap12BindingNamespace = "http://schemas.xmlsoap.org/wsdl/soap12/"
+)
+
+// SoapVersion describes the envelope and the HTTP content type of a SOAP protocol version
+type SoapVersion struct {
+	Version           string
+	EnvelopeNamespace string
+	MediaType         string
+}
+
+var (
+	Soap11 = SoapVersion{
+		Version:           "1.1",
+		EnvelopeNamespace: "http://schemas.xmlsoap.org/soap/envelope/",
+		MediaType:         "text/xml",
+	}
+	Soap12 = SoapVersion{
+		Version:           "1.2",
+		EnvelopeNamespace: "http://www.w3.org/2003/05/soap-envelope",
+		MediaType:         "application/soap+xml",
+	}
+)
+
+// ContentType : Returns the HTTP Content-Type for a request. SOAP 1.2 carries the action as a parameter of the
+// content type, SOAP 1.1 sends it in the SOAPAction header instead.
+func (v SoapVersion) ContentType(action string) string {
+	contentType := v.MediaType + "; charset=utf-8"
+	if v == Soap12 && action != "" {
+		contentType += fmt.Sprintf("; action=%q", action)
+	}
+
+	return contentType
+}
+
+// SoapVersion : Returns the SOAP version of the binding, detected by the namespace of its soap:binding element.
+// Bindings without a soap:binding element are treated as SOAP 1.1.
+func (b *Binding) SoapVersion() (SoapVersion, error) {
+	switch b.SoapBinding.XMLName.Space {
+	case soap11BindingNamespace, "":
+		return Soap11, nil
+	case soap12BindingNamespace:
+		return Soap12, nil
+	default:
+		return SoapVersion{}, fmt.Errorf("binding '%s' is not a soap binding: '%s'", b.Name, b.SoapBinding.XMLName.Space)
+	}
+}
@@ -1,6 +1,7 @@
 package wsdl
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
@@ -15,12 +16,16 @@ import (
 type InnerDefinitions struct {
 	TargetNamespace string `xml:"targetNamespace,attr"`
 
-	Imports  []Import  `xml:"import"`
-	Types    Type      `xml:"types"`
-	Messages []Message `xml:"message"`
-	PortType PortType  `xml:"portType"`
-	Binding  []Binding `xml:"binding"`
-	Service  Service   `xml:"service"`
+	Imports   []Import   `xml:"import"`
+	Types     Type       `xml:"types"`
+	Messages  []Message  `xml:"message"`
+	PortTypes []PortType `xml:"portType"`
+	Binding   []Binding  `xml:"binding"`
+	Services  []Service  `xml:"service"`
+
+	// PortType and Service are the first port type and service of the definitions, which most WSDLs only have one of
+	PortType PortType `xml:"-"`
+	Service  Service  `xml:"-"`
 }
 
 type Definitions struct {
@@ -52,6 +57,23 @@ func (d *Definitions) UnmarshalXML(decoder *xml.Decoder, start xml.StartElement)
 	d.XMLName = start.Name
 	d.Aliases = map[string]string{}
 
+	for i := range d.Services {
+		if len(d.Services[i].Ports) > 0 {
+			d.Services[i].Port = d.Services[i].Ports[0]
+		}
+	}
+	for i := range d.Messages {
+		if len(d.Messages[i].Parts) > 0 {
+			d.Messages[i].Part = d.Messages[i].Parts[0]
+		}
+	}
+	if len(d.PortTypes) > 0 {
+		d.PortType = d.PortTypes[0]
+	}
+	if len(d.Services) > 0 {
+		d.Service = d.Services[0]
+	}
+
 	d.Types.Schemas = xsd.SchemaMap{}
 	for _, schema := range d.Types.Schemata {
 		d.Types.Schemas[schema.TargetNamespace] = schema
@@ -80,32 +102,75 @@ func copyMap(src map[string]interface{}) map[string]interface{} {
 	return dst
 }
 
-func (d *Definitions) WriteRequest(operation string, w io.Writer, bodyParams map[string]interface{}) error {
-	//headerParams = copyMap(headerParams)
-	bodyParams = copyMap(bodyParams)
+// WriteRequest : Encodes a SOAP envelope for the given operation of the default port to w, see Port.WriteRequest
+func (d *Definitions) WriteRequest(operation string, w io.Writer, headerParams map[string]interface{}, bodyParams interface{}) error {
+	p, err := d.Port("", "")
+	if err != nil {
+		return err
+	}
+
+	return p.WriteRequest(operation, w, headerParams, bodyParams)
+}
+
+// WriteRequest : Encodes a SOAP envelope for the given operation of the binding of the port to w. Header params are
+// encoded against the soap:header messages of the binding operation's input; headers without any params are left out.
+// The body is either a map of slash paths or a struct, see xsd.Params.
+// Params which match no schema element are returned as xsd.UnknownParamsError after the whole request is written.
+func (p *Port) WriteRequest(operation string, w io.Writer, headerParams map[string]interface{}, bodyParams interface{}) error {
+	d := p.defs
+	headerParams = copyMap(headerParams)
 
 	var bndOp BindingOperation
 	var ptOp PortTypeOperation
 	var err error
-	bndOp, ptOp, err = d.getOperations(operation)
+	bndOp, ptOp, err = p.getOperations(operation)
 	if err != nil {
 		return err
 	}
 	// fmt.Println("bndOp", bndOp)
 	// fmt.Println("ptOp", ptOp)
 
-	var body xsd.Schema
-	var bodyElement string
-	var bodyService *Definitions
-	// TODO: implement proper handling, tho I can't really find a SoapHeader part for the binding operation
-	/*
-		header, headerElement, err = d.getSchema(bndOp.Input.SoapHeader.PortTypeOperationMessage)
-		if err != nil {
-			return
+	var version SoapVersion
+	version, err = p.GetSoapVersion()
+	if err != nil {
+		return err
+	}
+
+	headers, err := d.getHeaders(bndOp.Input.SoapHeaders, headerParams)
+	if err != nil {
+		return err
+	}
+
+	var style string
+	style, err = p.getStyle(bndOp)
+	if err != nil {
+		return err
+	}
+
+	var parts []messagePart
+	parts, err = d.getParts(bndOp.Input.SoapBody.Parts, bndOp.Input.SoapBody.PortTypeOperationMessage, ptOp.Input)
+	if err != nil {
+		return err
+	}
+
+	// The params of rpc style operations are below the operation element, those of document style operations below
+	// the elements of the parts. Struct params for several parts have a field per part.
+	var root string
+	switch {
+	case style == rpcStyle:
+		root = operation
+	case len(parts) == 1:
+		root = parts[0].element
+	}
+
+	for _, part := range parts {
+		if style != rpcStyle && part.Element == "" {
+			return fmt.Errorf("part '%s' of document style operation '%s' has no element", part.Name, operation)
 		}
-	*/
+	}
 
-	body, bodyElement, bodyService, err = d.getSchema(bndOp.Input.SoapBody.PortTypeOperationMessage, ptOp.Input)
+	var params map[string]interface{}
+	params, err = xsd.Params(root, bodyParams)
 	if err != nil {
 		return err
 	}
@@ -114,7 +179,7 @@ func (d *Definitions) WriteRequest(operation string, w io.Writer, bodyParams map
 	if err != nil {
 		return err
 	}
-	enc := xml.NewEncoder(w)
+	enc := xsd.NewEncoder(xml.NewEncoder(w))
 	//enc := xml.NewEncoder(io.MultiWriter(w, os.Stdout))
 	enc.Indent("", "  ")
 	defer func() {
@@ -126,7 +191,7 @@ func (d *Definitions) WriteRequest(operation string, w io.Writer, bodyParams map
 	envName := "soap-env"
 	envelope := xml.StartElement{
 		Name: xml.Name{
-			Space:  "http://schemas.xmlsoap.org/soap/envelope/",
+			Space:  version.EnvelopeNamespace,
 			Prefix: envName,
 			Local:  "Envelope",
 		},
@@ -156,19 +221,30 @@ func (d *Definitions) WriteRequest(operation string, w io.Writer, bodyParams map
 		_ = enc.EncodeToken(envelope.End())
 	}()
 
-	// soapHeader := xml.StartElement{
-	// 	Name: xml.Name{
-	// 		Space: "http://schemas.xmlsoap.org/soap/envelope/",
-	// 		Local: "Header",
-	// 	},
-	// }
-	//enc.EncodeToken(soapHeader)
+	if len(headers) > 0 {
+		soapHeader := xml.StartElement{
+			Name: xml.Name{
+				Prefix: envName,
+				Local:  "Header",
+			},
+		}
+		err = enc.EncodeToken(soapHeader)
+		if err != nil {
+			return err
+		}
 
-	// err = header.EncodeElement(headerElement, enc, d.Types.Schemas, headerParams)
-	// if err != nil {
-	// 	return
-	// }
-	//enc.EncodeToken(soapHeader.End())
+		for _, h := range headers {
+			err = h.schema.EncodeElement(h.element, enc, h.service.Types.Schemas, headerParams)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = enc.EncodeToken(soapHeader.End())
+		if err != nil {
+			return err
+		}
+	}
 
 	soapBody := xml.StartElement{
 		Name: xml.Name{
@@ -181,7 +257,34 @@ func (d *Definitions) WriteRequest(operation string, w io.Writer, bodyParams map
 		return err
 	}
 
-	err = body.EncodeElement(bodyElement, enc, bodyService.Types.Schemas, bodyParams, true, false)
+	if body := bndOp.Input.SoapBody; body.Use == encodedUse {
+		style := body.EncodingStyle
+		if style == "" {
+			style = xsd.SOAPEncodingNamespace
+		}
+		enc.SetEncoding(xml.Attr{Name: xml.Name{Prefix: envName, Local: "encodingStyle"}, Value: style})
+	}
+
+	if style == rpcStyle {
+		err = encodeRPC(enc, operation, bndOp.Input.SoapBody.Namespace, parts, params)
+	} else {
+		for _, part := range parts {
+			err = part.schema.EncodeElement(part.element, enc, part.service.Types.Schemas, params)
+			if err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	// Header params are shared by all operations, so only those below a header of this operation can be unknown
+	enc.Unknown(paramsBelow(headerParams, headers))
+	enc.Unknown(params)
+
+	// Every violation of the schema found in the header and the body is reported at once
+	err = enc.Err()
 	if err != nil {
 		return err
 	}
@@ -191,156 +294,291 @@ func (d *Definitions) WriteRequest(operation string, w io.Writer, bodyParams map
 		return err
 	}
 
-	return nil
+	// The request is complete, it is up to the caller to send it anyways
+	return enc.UnknownErr()
+}
+
+type header struct {
+	schema  xsd.Schema
+	element string
+	service *Definitions
 }
 
-func (d *Definitions) getSchema(msg ...PortTypeOperationMessage) (schema xsd.Schema, element string, service *Definitions, err error) {
-	for _, s := range msg {
-		service = d
-		if s.Message == "" {
+// getHeaders resolves the schema elements of the given soap:header bindings, skipping every header for which no
+// params have been submitted
+func (d *Definitions) getHeaders(soapHeaders []SoapBody, params map[string]interface{}) ([]header, error) {
+	headers := []header{}
+	for _, h := range soapHeaders {
+		parts, err := d.getParts(h.Part, h.PortTypeOperationMessage)
+		if err != nil {
+			return nil, err
+		}
+
+		part := parts[0]
+		if part.Element == "" {
+			return nil, fmt.Errorf("header part '%s' has no element", part.Name)
+		}
+
+		if !hasParams(params, part.element) {
 			continue
 		}
 
-		parts := strings.Split(s.Message, ":")
-		if len(parts) == 2 {
-			if service.GetNamespace(parts[0]) != service.TargetNamespace {
-				if _, ok := service.ImportDefinitions[parts[0]]; !ok {
-					err = fmt.Errorf("cannot find '%s' namespace", parts[0])
-					return
-				}
-				temp := service.ImportDefinitions[parts[0]]
-				service = &temp
-			}
+		headers = append(headers, header{schema: part.schema, element: part.element, service: part.service})
+	}
 
-			element = parts[1]
-			var ok bool
-			schema, ok = service.Types.Schemas[service.GetNamespace(parts[0])]
-			if ok {
-				for _, m := range service.Messages {
-					if m.Name == element {
-						p := strings.Split(m.Part.Element, ":")
-						if len(p) != 2 {
-							err = fmt.Errorf("invalid message part element name '%s'", m.Part.Element)
-							return
-						}
-
-						element = p[1]
-						return
-					}
-				}
+	return headers, nil
+}
 
-				err = fmt.Errorf("did not find message '%s'", element)
-				return
+// paramsBelow returns the params submitted for any of the headers
+func paramsBelow(params map[string]interface{}, headers []header) map[string]interface{} {
+	below := map[string]interface{}{}
+	for k, v := range params {
+		for _, h := range headers {
+			if k == h.element || strings.HasPrefix(k, h.element+"/") {
+				below[k] = v
 			}
-		} else {
-			err = fmt.Errorf("invalid soapheader message format '%s'", s.Message)
 		}
 	}
 
-	err = fmt.Errorf("did not find schema in %q", msg)
-	return
+	return below
 }
 
-func (d *Definitions) getOperations(operation string) (bndOp BindingOperation, ptOp PortTypeOperation, err error) {
-	service := *d
-	parts := strings.Split(service.Service.Port.Binding, ":")
-	switch len(parts) {
-	case 2:
-		if service.GetNamespace(parts[0]) != service.TargetNamespace {
-			err = fmt.Errorf("have '%s', want '%s' as target namespace", parts[0], service.TargetNamespace)
-			return
-		}
-
-		parts[0] = parts[1]
-		fallthrough
-	case 1:
-		for _, bnd := range service.Binding {
-			if bnd.Name == parts[0] {
-				parts = strings.Split(bnd.Type, ":")
-
-				switch len(parts) {
-				case 2:
-					if service.GetNamespace(parts[0]) != service.TargetNamespace {
-						if _, ok := service.ImportDefinitions[parts[0]]; !ok {
-							err = fmt.Errorf("cannot find '%s' namespace in binding %s", parts[0], bnd.Name)
-							return
-						}
-						service = service.ImportDefinitions[parts[0]]
-					}
-					parts[0] = parts[1]
-					fallthrough
-				case 1:
-					if service.PortType.Name != parts[0] {
-						err = fmt.Errorf("have '%s', want '%s' as target namespace in binding '%s'", parts[0], service.PortType.Name, bnd.Name)
-						return
-					}
+func hasParams(params map[string]interface{}, element string) bool {
+	for k := range params {
+		if k == element || strings.HasPrefix(k, element+"/") {
+			return true
+		}
+	}
 
-					var found bool
-					for _, ptOp = range service.PortType.Operations {
-						found = ptOp.Name == operation
-						if found {
-							break
-						}
-					}
+	return false
+}
+
+// messagePart is a part of a message. Parts with an element are resolved to the schema declaring it.
+type messagePart struct {
+	Part
+	schema  xsd.Schema
+	element string
+	service *Definitions // The definitions declaring the message, whose schemas encode the part
+}
+
+// getParts resolves the parts of the first of msgs which references a message. If names, a space separated list of
+// part names, is not empty only those parts are returned, in its order.
+func (d *Definitions) getParts(names string, msgs ...PortTypeOperationMessage) ([]messagePart, error) {
+	for _, msg := range msgs {
+		if msg.Message == "" {
+			continue
+		}
+
+		service := d
+		name := strings.Split(msg.Message, ":")
+		if len(name) != 2 {
+			return nil, fmt.Errorf("invalid message format '%s'", msg.Message)
+		}
+
+		if service.GetNamespace(name[0]) != service.TargetNamespace {
+			imported, ok := service.ImportDefinitions[name[0]]
+			if !ok {
+				return nil, fmt.Errorf("cannot find '%s' namespace", name[0])
+			}
+			service = &imported
+		}
+
+		var message *Message
+		for i := range service.Messages {
+			if service.Messages[i].Name == name[1] {
+				message = &service.Messages[i]
+			}
+		}
+		if message == nil {
+			return nil, fmt.Errorf("did not find message '%s'", name[1])
+		}
 
-					if !found {
-						err = fmt.Errorf("did not find porttype operation '%s' in binding '%s'", operation, bnd.Name)
-						return
+		parts := message.Parts
+		if names != "" {
+			parts = nil
+			for _, n := range strings.Fields(names) {
+				var found bool
+				for _, part := range message.Parts {
+					if part.Name == n {
+						parts = append(parts, part)
+						found = true
 					}
-				default:
-					err = fmt.Errorf("malformed binding information '%s' in binding '%s'", bnd.Type, bnd.Name)
-					return
 				}
 
-				for _, bndOp = range bnd.Operations {
-					if bndOp.Name == operation {
-						return
-					}
+				if !found {
+					return nil, fmt.Errorf("did not find part '%s' in message '%s'", n, message.Name)
+				}
+			}
+		}
+
+		if len(parts) == 0 {
+			return nil, fmt.Errorf("message '%s' has no parts", message.Name)
+		}
+
+		resolved := make([]messagePart, len(parts))
+		for i, part := range parts {
+			resolved[i] = messagePart{Part: part, service: service}
+			switch {
+			case part.Element != "":
+				p := strings.Split(part.Element, ":")
+				if len(p) != 2 {
+					return nil, fmt.Errorf("invalid message part element name '%s'", part.Element)
 				}
 
-				err = fmt.Errorf("did not find operation '%s' in binding '%s'", operation, bnd.Name)
-				return
+				schema, ok := service.Types.Schemas[service.GetNamespace(p[0])]
+				if !ok {
+					return nil, fmt.Errorf("did not find schema '%s' of message part '%s'", service.GetNamespace(p[0]), part.Name)
+				}
+				resolved[i].schema, resolved[i].element = schema, p[1]
+			case part.Type == "":
+				return nil, fmt.Errorf("message part '%s' has neither an element nor a type", part.Name)
 			}
 		}
 
-		err = fmt.Errorf("did not find binding '%s'", parts[0])
-	default:
-		err = fmt.Errorf("malformed binding information: '%s'", d.Service.Port.Binding)
+		return resolved, nil
+	}
+
+	return nil, fmt.Errorf("did not find schema in %q", msgs)
+}
+
+// GetFaults : Returns the message part element of every fault declared for the operation of the default port
+func (d *Definitions) GetFaults(operation string) (map[string]xml.Name, error) {
+	p, err := d.Port("", "")
+	if err != nil {
+		return nil, err
 	}
 
-	return
+	return p.GetFaults(operation)
+}
+
+// GetFaults : Returns the message part element of every fault declared for the operation, keyed by fault name
+func (p *Port) GetFaults(operation string) (map[string]xml.Name, error) {
+	_, ptOp, err := p.getOperations(operation)
+	if err != nil {
+		return nil, err
+	}
+
+	faults := map[string]xml.Name{}
+	for _, f := range ptOp.Faults {
+		var element xml.Name
+		element, err = p.defs.getMessageElement(f.Message)
+		if err != nil {
+			return nil, err
+		}
+
+		faults[f.Name] = element
+	}
+
+	return faults, nil
+}
+
+// getMessageElement returns the namespace and name of the element referenced by the part of the given message
+func (d *Definitions) getMessageElement(message string) (xml.Name, error) {
+	service := d
+	parts := strings.Split(message, ":")
+	if len(parts) != 2 {
+		return xml.Name{}, fmt.Errorf("invalid message format '%s'", message)
+	}
+
+	if service.GetNamespace(parts[0]) != service.TargetNamespace {
+		temp, ok := service.ImportDefinitions[parts[0]]
+		if !ok {
+			return xml.Name{}, fmt.Errorf("cannot find '%s' namespace", parts[0])
+		}
+		service = &temp
+	}
+
+	for _, m := range service.Messages {
+		if m.Name == parts[1] {
+			p := strings.Split(m.Part.Element, ":")
+			if len(p) != 2 {
+				return xml.Name{}, fmt.Errorf("invalid message part element name '%s'", m.Part.Element)
+			}
+
+			return xml.Name{Space: service.GetNamespace(p[0]), Local: p[1]}, nil
+		}
+	}
+
+	return xml.Name{}, fmt.Errorf("did not find message '%s'", parts[1])
 }
 
 // Unmarhsals the WSDL definitions into the Definitions struct
 func (d *Definitions) GetDefinitions(client *client.Client, url string) error {
-	return client.MakeRequest("GET", url, nil, d)
+	return d.GetDefinitionsContext(context.Background(), client, url)
+}
+
+// GetDefinitionsContext : Like GetDefinitions, but the request is bound to ctx
+func (d *Definitions) GetDefinitionsContext(ctx context.Context, client *client.Client, url string) error {
+	return client.MakeRequestContext(ctx, "GET", url, nil, nil, d)
 }
 
 // Gets the base wsdl import, binding and operation definitions, adds imports and schema definitions
 func (d *Definitions) GetService(client *client.Client, url string) error {
-	err := d.GetDefinitions(client, url)
+	return d.GetServiceContext(context.Background(), client, url)
+}
+
+// GetServiceContext : Like GetService, but all requests are bound to ctx
+func (d *Definitions) GetServiceContext(ctx context.Context, client *client.Client, url string) error {
+	return d.LoadServiceContext(ctx, NewClientLoader(client), url)
+}
+
+// LoadServiceContext : Like GetServiceContext, but the definitions at location and their imports are loaded by
+// loader, for example from a file system by NewFSLoader
+func (d *Definitions) LoadServiceContext(ctx context.Context, loader Loader, location string) error {
+	err := loader.Load(ctx, location, d)
 	if err != nil {
 		return err
 	}
 
-	if d.Service.Name == "" {
-		err = fmt.Errorf("invalid service name '%s' for url '%s'", d.Service.Name, url)
+	return d.addService(ctx, loader, location)
+}
+
+// ReadServiceContext : Like LoadServiceContext, but the definitions are read from r. As they have no location, only
+// imports given by an absolute location can be loaded.
+func (d *Definitions) ReadServiceContext(ctx context.Context, loader Loader, r io.Reader) error {
+	err := decodeDocument(ctx, r, d)
+	if err != nil {
 		return err
 	}
-	log.Printf("adding service '%s' from '%s'", d.Service.Name, url)
+
+	return d.addService(ctx, loader, "")
+}
+
+// addService checks the services of the definitions loaded from location and adds their imports
+func (d *Definitions) addService(ctx context.Context, loader Loader, location string) error {
+	if len(d.Services) == 0 {
+		return fmt.Errorf("did not find a service for url '%s'", location)
+	}
+
+	for _, service := range d.Services {
+		if service.Name == "" {
+			return fmt.Errorf("invalid service name '%s' for url '%s'", service.Name, location)
+		}
+		log.Printf("adding service '%s' from '%s'", service.Name, location)
+	}
 
 	log.Printf("adding all imports")
-	err = d.AddImports(client)
+	err := d.addImports(ctx, loader, location)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return d.addSchemas(ctx, loader, location)
 }
 
 // AddImports : Gets wsdl schema definitions and recursively adds any additional imports - for example, if the
 // WSDL itself has an import to fetch the type definitions separately from the bindings and operations
 func (d *Definitions) AddImports(client *client.Client) error {
+	return d.AddImportsContext(context.Background(), client)
+}
+
+// AddImportsContext : Like AddImports, but all requests are bound to ctx
+func (d *Definitions) AddImportsContext(ctx context.Context, client *client.Client) error {
+	return d.addImports(ctx, NewClientLoader(client), "")
+}
+
+// addImports loads the imports of the definitions at base, and recursively their own imports, by loader
+func (d *Definitions) addImports(ctx context.Context, loader Loader, base string) error {
 	imports := []Import{}
 	for _, val := range d.Imports {
 		imports = append(imports, val)
@@ -352,18 +590,28 @@ func (d *Definitions) AddImports(client *client.Client) error {
 			continue
 		}
 
-		log.Printf("adding import from '%s'", imports[i].Location)
+		location, err := loader.Resolve(base, imports[i].Location)
+		if err != nil {
+			return err
+		}
+
+		log.Printf("adding import from '%s'", location)
 		definitions := &Definitions{
 			Aliases:           make(map[string]string),
 			ImportDefinitions: make(map[string]Definitions),
 		}
 
-		err := definitions.GetDefinitions(client, imports[i].Location)
+		err = loader.Load(ctx, location, definitions)
+		if err != nil {
+			return err
+		}
+
+		err = definitions.addImports(ctx, loader, location)
 		if err != nil {
 			return err
 		}
 
-		err = definitions.AddImports(client)
+		err = definitions.addSchemas(ctx, loader, location)
 		if err != nil {
 			return err
 		}
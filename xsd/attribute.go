@@ -0,0 +1,312 @@
+package xsd
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/sezzle/sezzle-go-xml"
+)
+
+type Attribute struct {
+	XMLName    xml.Name    `xml:"http://www.w3.org/2001/XMLSchema attribute"`
+	Name       string      `xml:"name,attr"`
+	Ref        string      `xml:"ref,attr"`
+	Type       string      `xml:"type,attr"`
+	Use        string      `xml:"use,attr"` // optional (default), required or prohibited
+	Default    string      `xml:"default,attr"`
+	Fixed      string      `xml:"fixed,attr"`
+	Form       string      `xml:"form,attr"`
+	SimpleType *SimpleType `xml:"http://www.w3.org/2001/XMLSchema simpleType"`
+	ArrayType  string      `xml:"http://schemas.xmlsoap.org/wsdl/ arrayType,attr"` // The item type of a soapenc:Array, like "xsd:string[]"
+
+	// global is set for the top level attributes of a schema, which are always qualified
+	global bool
+}
+
+// AttributeGroup is either the definition of a named group of attributes, or a reference to one by Ref
+type AttributeGroup struct {
+	XMLName         xml.Name         `xml:"http://www.w3.org/2001/XMLSchema attributeGroup"`
+	Name            string           `xml:"name,attr"`
+	Ref             string           `xml:"ref,attr"`
+	Attributes      []Attribute      `xml:"http://www.w3.org/2001/XMLSchema attribute"`
+	AttributeGroups []AttributeGroup `xml:"http://www.w3.org/2001/XMLSchema attributeGroup"`
+	AnyAttribute    *AnyAttribute    `xml:"http://www.w3.org/2001/XMLSchema anyAttribute"`
+}
+
+// AnyAttribute allows attributes which are not declared by the schema
+type AnyAttribute struct {
+	XMLName         xml.Name `xml:"http://www.w3.org/2001/XMLSchema anyAttribute"`
+	Namespace       string   `xml:"namespace,attr"`
+	ProcessContents string   `xml:"processContents,attr"`
+}
+
+// Encode : Returns the attributes of the group, including those of all groups it references
+func (g *AttributeGroup) Encode(enc *Encoder, sr SchemaRepository, ga GetAliaser, params map[string]interface{}, path ...string) (attrs []xml.Attr, anyAttribute bool, err error) {
+	return encodeAttributes(enc, sr, ga, g.Attributes, g.AttributeGroups, g.AnyAttribute, params, path...)
+}
+
+// AttributePath returns the param path of the attribute name of the element at path, like "Item/@id". An attribute
+// can't be sent with an empty value, since the xml encoder writes attributes without a value as namespace
+// declarations. It is left out and reported as UnknownParam, which fails the request with strict params.
+func AttributePath(path []string, name string) []string {
+	return appendPath(path, "@"+name)
+}
+
+// encodeAttributes returns the attributes declared directly or by attribute groups for the element at path. Their
+// values are taken from the params at AttributePath. anyAttribute reports whether undeclared attributes are allowed.
+func encodeAttributes(enc *Encoder, sr SchemaRepository, ga GetAliaser, attributes []Attribute, groups []AttributeGroup, any *AnyAttribute, params map[string]interface{}, path ...string) (attrs []xml.Attr, anyAttribute bool, err error) {
+	anyAttribute = any != nil
+	for _, a := range attributes {
+		var attr *xml.Attr
+		attr, err = a.Encode(enc, sr, ga, params, path...)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if attr != nil {
+			attrs = append(attrs, *attr)
+		}
+	}
+
+	for _, g := range groups {
+		parts := splitQName(g.Ref)
+		if len(parts) != 2 {
+			return nil, false, fmt.Errorf("malformed attribute group ref '%s' in path %q", g.Ref, path)
+		}
+
+		var schema Schemaer
+		schema, err = sr.GetSchema(ga.GetAlias(parts[0]))
+		if err != nil {
+			return nil, false, err
+		}
+
+		var groupAttrs []xml.Attr
+		var groupAny bool
+		groupAttrs, groupAny, err = schema.EncodeAttributeGroup(parts[1], enc, sr, params, path...)
+		if err != nil {
+			return nil, false, err
+		}
+
+		attrs = append(attrs, groupAttrs...)
+		anyAttribute = anyAttribute || groupAny
+	}
+
+	return attrs, anyAttribute, nil
+}
+
+// Encode : Returns the attribute with the value submitted at its AttributePath, its fixed or its default value. If
+// there is none, nil is returned and a missing required attribute is reported.
+func (a *Attribute) Encode(enc *Encoder, sr SchemaRepository, ga GetAliaser, params map[string]interface{}, path ...string) (*xml.Attr, error) {
+	name := a.localName()
+	if enc.restricted[name] {
+		return nil, nil
+	}
+
+	if a.Ref != "" {
+		ref, refSchema, err := resolveAttribute(a.Ref, sr, ga)
+		if err != nil {
+			return nil, err
+		}
+
+		// The use of a global attribute is up to the reference, which may also give it a default or fixed value
+		resolved := *ref
+		resolved.Use = a.Use
+		if a.Default != "" {
+			resolved.Default = a.Default
+		}
+		if a.Fixed != "" {
+			resolved.Fixed = a.Fixed
+		}
+		resolved.global = true
+		return resolved.Encode(enc, sr, refSchema, params, path...)
+	}
+
+	// Local attributes are qualified by their form, or the attributeFormDefault of their schema
+	attrName := xml.Name{Local: name}
+	if a.global || ga.QualifiesAttribute(a.Form) {
+		attrName.Space = ga.Namespace()
+	}
+
+	attrPath := AttributePath(path, name)
+	enc.expand(attrPath)
+
+	take := takeParam
+	if a.isList(sr, ga) {
+		take = takeList
+	}
+
+	v, ok := take(params, MakePath(attrPath))
+	if !ok {
+		switch {
+		case a.Fixed != "":
+			return &xml.Attr{Name: attrName, Value: a.Fixed}, nil
+		case a.Default != "":
+			return &xml.Attr{Name: attrName, Value: a.Default}, nil
+		case a.Use == "required":
+			enc.Invalid(attrPath, "required attribute '%s' is missing", name)
+		}
+		return nil, nil
+	}
+	enc.consumed++
+
+	if a.Use == "prohibited" {
+		enc.Invalid(attrPath, "attribute '%s' is prohibited", name)
+		return nil, nil
+	}
+
+	value, err := a.format(enc, sr, ga, v, attrPath...)
+	if enc.invalidValue(attrPath, err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if a.Fixed != "" && value != a.Fixed {
+		enc.Invalid(attrPath, "attribute '%s' has the fixed value %q, got %q", name, a.Fixed, value)
+	}
+
+	// The xml encoder writes attributes without a value as namespace declarations, so an empty value is left out
+	if value == "" {
+		if a.Use == "required" {
+			enc.Invalid(attrPath, "required attribute '%s' can't be empty", name)
+		} else {
+			enc.dropEmpty(attrPath)
+		}
+		return nil, nil
+	}
+
+	return &xml.Attr{Name: attrName, Value: value}, nil
+}
+
+// resolveAttribute returns the global attribute referenced by ref, and the schema it is defined in
+func resolveAttribute(ref string, sr SchemaRepository, ga GetAliaser) (*Attribute, GetAliaser, error) {
+	parts := splitQName(ref)
+	if len(parts) != 2 {
+		return nil, nil, fmt.Errorf("malformed attribute ref '%s'", ref)
+	}
+
+	schema, err := sr.GetSchema(ga.GetAlias(parts[0]))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return schema.GetAttribute(parts[1])
+}
+
+// qualifyAttrs returns attrs with the prefixes of the namespaces of the qualified ones, preceded by the declarations
+// of those no enclosing element declared. The returned func ends the scope of the declarations, it has to be called
+// when the element ends.
+func qualifyAttrs(enc *Encoder, attrs []xml.Attr) ([]xml.Attr, func()) {
+	var decls, qualified []xml.Attr
+	var ends []func()
+	for _, attr := range attrs {
+		if attr.Name.Space != "" {
+			prefix, decl, end := enc.declareNamespace(attr.Name.Space)
+			decls = append(decls, decl...)
+			ends = append(ends, end)
+			attr.Name = xml.Name{Prefix: prefix, Local: attr.Name.Local}
+		}
+		qualified = append(qualified, attr)
+	}
+
+	return append(decls, qualified...), func() {
+		for _, end := range ends {
+			end()
+		}
+	}
+}
+
+// localName returns the name of the attribute, or of the global attribute it references
+func (a *Attribute) localName() string {
+	if a.Name != "" {
+		return a.Name
+	}
+
+	return a.Ref[strings.Index(a.Ref, ":")+1:]
+}
+
+// format returns the lexical representation of v for the type of the attribute
+func (a *Attribute) format(enc *Encoder, sr SchemaRepository, ga GetAliaser, v interface{}, path ...string) (string, error) {
+	if a.SimpleType != nil {
+		return a.SimpleType.Format(enc, sr, ga, v, path...)
+	}
+
+	if a.Type == "" {
+		// An attribute without a type is of xs:anySimpleType
+		return formatAnyValue(v), nil
+	}
+
+	return formatType(enc, sr, ga, a.Type, v, path...)
+}
+
+// isList reports whether the values of the attribute are lists
+func (a *Attribute) isList(sr SchemaRepository, ga GetAliaser) bool {
+	if a.SimpleType != nil {
+		return a.SimpleType.isList(sr, ga)
+	}
+
+	return isListType(sr, ga, a.Type)
+}
+
+// encodeAnyAttributes returns every attribute submitted for the element at path which has not been consumed by a
+// declared attribute, sorted by name
+func encodeAnyAttributes(enc *Encoder, params map[string]interface{}, path ...string) []xml.Attr {
+	prefix := MakePath(path) + "/@"
+
+	var names []string
+	for k := range params {
+		if strings.HasPrefix(k, prefix) && !strings.Contains(k[len(prefix):], "/") {
+			names = append(names, k[len(prefix):])
+		}
+	}
+	sort.Strings(names)
+
+	var attrs []xml.Attr
+	for _, name := range names {
+		v, ok := takeParam(params, prefix+name)
+		if !ok {
+			continue
+		}
+		enc.consumed++
+		enc.expand(AttributePath(path, name))
+
+		value := formatAnyValue(v)
+		if value == "" {
+			enc.dropEmpty(AttributePath(path, name))
+			continue
+		}
+
+		attrs = append(attrs, xml.Attr{Name: xml.Name{Local: name}, Value: value})
+	}
+
+	return attrs
+}
+
+// takeParam removes the value at key from params. Of a slice, only the first value is taken and the rest is left
+// for the next occurrence.
+func takeParam(params map[string]interface{}, key string) (interface{}, bool) {
+	v, ok := params[key]
+	if !ok {
+		return nil, false
+	}
+
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Slice || isScalar(val.Type()) {
+		delete(params, key)
+		return v, true
+	}
+
+	if val.Len() <= 1 {
+		delete(params, key)
+		if val.Len() == 0 {
+			return nil, false
+		}
+	} else {
+		params[key] = val.Slice(1, val.Len()).Interface()
+	}
+
+	return val.Index(0).Interface(), true
+}
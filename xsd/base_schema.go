@@ -3,6 +3,8 @@ package xsd
 import (
 	"fmt"
 	"reflect"
+	"strconv"
+	"strings"
 
 	"github.com/sezzle/sezzle-go-xml"
 )
@@ -10,36 +12,109 @@ import (
 type mapping struct {
 	xsdSchema []string
 	kinds     []reflect.Kind
-	format    string
+	format    func(v reflect.Value) string
+	// lexical checks the formatted value against the lexical space of the xsd type and returns its canonical form
+	lexical func(name, s string) (string, error)
 }
 
+var (
+	intKinds   = []reflect.Kind{reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr}
+	floatKinds = []reflect.Kind{reflect.Float32, reflect.Float64}
+)
+
 // These mappings are used to map between a xsd type which has a base like
 // '<restriction base="string"/>'.
 var mappings = []mapping{
 	{
 		xsdSchema: []string{"boolean"},
 		kinds:     []reflect.Kind{reflect.Bool},
-		format:    "%t",
+		format:    formatBool,
+	},
+	{
+		xsdSchema: []string{"boolean"},
+		kinds:     []reflect.Kind{reflect.String},
+		format:    formatString,
+		lexical:   lexicalBoolean,
+	},
+	{
+		xsdSchema: []string{"integer", "long", "int", "short", "byte", "nonNegativeInteger", "positiveInteger", "nonPositiveInteger", "negativeInteger", "unsignedLong", "unsignedInt", "unsignedShort", "unsignedByte"},
+		kinds:     intKinds,
+		format:    formatInt,
+		lexical:   lexicalInteger,
+	},
+	{
+		xsdSchema: []string{"integer", "long", "int", "short", "byte", "nonNegativeInteger", "positiveInteger", "nonPositiveInteger", "negativeInteger", "unsignedLong", "unsignedInt", "unsignedShort", "unsignedByte"},
+		kinds:     []reflect.Kind{reflect.String},
+		format:    formatString,
+		lexical:   lexicalInteger,
+	},
+	{
+		xsdSchema: []string{"decimal"},
+		kinds:     intKinds,
+		format:    formatInt,
+	},
+	{
+		xsdSchema: []string{"decimal"},
+		kinds:     floatKinds,
+		format:    formatDecimal,
+		lexical:   lexicalDecimal,
+	},
+	{
+		xsdSchema: []string{"decimal"},
+		kinds:     []reflect.Kind{reflect.String},
+		format:    formatString,
+		lexical:   lexicalDecimal,
+	},
+	{
+		xsdSchema: []string{"float", "double"},
+		kinds:     floatKinds,
+		format:    formatFloat,
+		lexical:   lexicalFloat,
+	},
+	{
+		xsdSchema: []string{"float", "double"},
+		kinds:     intKinds,
+		format:    formatInt,
+	},
+	{
+		xsdSchema: []string{"float", "double"},
+		kinds:     []reflect.Kind{reflect.String},
+		format:    formatString,
+		lexical:   lexicalFloat,
 	},
 	{
-		xsdSchema: []string{"int", "long", "integer"},
-		kinds:     []reflect.Kind{reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr},
-		format:    "%d",
+		xsdSchema: []string{"string", "anyURI"},
+		kinds:     []reflect.Kind{reflect.String},
+		format:    formatString,
 	},
 	{
-		xsdSchema: []string{"float"},
-		kinds:     []reflect.Kind{reflect.Float32, reflect.Float64},
-		format:    "%f",
+		xsdSchema: []string{"normalizedString", "token", "language", "Name", "NCName", "NMTOKEN", "NMTOKENS", "ID", "IDREF", "IDREFS", "ENTITY", "ENTITIES", "QName", "NOTATION"},
+		kinds:     []reflect.Kind{reflect.String},
+		format:    formatString,
+		lexical:   lexicalString,
 	},
 	{
-		xsdSchema: []string{"string"},
+		xsdSchema: []string{"dateTime", "date", "time", "duration", "gYearMonth", "gYear", "gMonthDay", "gDay", "gMonth"},
 		kinds:     []reflect.Kind{reflect.String},
-		format:    "%s",
+		format:    formatString,
+		lexical:   lexicalDate,
+	},
+	{
+		xsdSchema: []string{"gYear", "gMonth", "gDay"},
+		kinds:     intKinds,
+		format:    formatInt,
+		lexical:   lexicalCalendarNumber,
 	},
 	{
-		xsdSchema: []string{"dateTime"},
+		xsdSchema: []string{"base64Binary", "hexBinary"},
 		kinds:     []reflect.Kind{reflect.String},
-		format:    "%s",
+		format:    formatString,
+		lexical:   lexicalBinary,
+	},
+	{
+		xsdSchema: []string{"anySimpleType", "anyType"},
+		kinds:     append(append([]reflect.Kind{reflect.Bool, reflect.String}, intKinds...), floatKinds...),
+		format:    formatAny,
 	},
 }
 
@@ -50,71 +125,149 @@ var mappings = []mapping{
 type baseSchema struct{}
 
 // http://www.w3.org/2001/XMLSchema-datatypes does not have elements.
-func (b baseSchema) EncodeElement(name string, enc *xml.Encoder, sr SchemaRepository, params map[string]interface{}, useNamespace, keepUsingNamespace bool, path ...string) error {
+func (b baseSchema) EncodeElement(name string, enc *Encoder, sr SchemaRepository, params map[string]interface{}, path ...string) error {
 	return fmt.Errorf("not implemented")
 }
 
-func (b baseSchema) EncodeType(name string, enc *xml.Encoder, sr SchemaRepository, params map[string]interface{}, useNamespace, keepUsingNamespace bool, path ...string) error {
-	v, ok := params[MakePath(path)]
+func (b baseSchema) EncodeType(name string, enc *Encoder, sr SchemaRepository, params map[string]interface{}, path ...string) error {
+	take := takeParam
+	if builtinListItems[name] != "" {
+		take = takeList
+	}
+
+	v, ok := take(params, MakePath(path))
 	if !ok {
-		err := fmt.Errorf("did not find data '%s' in path", MakePath(path))
-		fmt.Println(err)
-		//return err
-		return err
+		enc.Invalid(path, "did not find data '%s' in path", MakePath(path))
+		return nil
 	}
+	enc.consumed++
 
-	var del bool
-	var newVal interface{}
-	del, newVal, err := encodeInterfaceType(name, enc, v)
+	s, err := b.FormatType(name, enc, sr, v, path...)
+	if enc.invalidValue(path, err) {
+		return nil
+	}
 	if err != nil {
 		return err
 	}
 
-	if newVal != nil {
-		params[MakePath(path)] = newVal
-	}
+	return enc.EncodeToken(xml.CharData(s))
+}
 
-	if del {
-		delete(params, MakePath(path))
-	}
-	return nil
+// The base types don't have attributes
+func (b baseSchema) EncodeTypeAttributes(name string, enc *Encoder, sr SchemaRepository, params map[string]interface{}, path ...string) ([]xml.Attr, bool, error) {
+	return nil, false, nil
 }
 
-func encodeInterfaceType(name string, enc *xml.Encoder, v interface{}) (del bool, newVal interface{}, err error) {
-	val := reflect.ValueOf(v)
-	if val.Kind() == reflect.Slice {
-		if val.Len() == 0 {
-			del = true
-			return
-		}
+// xs:anyType is the only built-in complex type, it has no content model to encode.
+func (b baseSchema) GetComplexType(name string) (*ComplexType, GetAliaser, error) {
+	return nil, nil, fmt.Errorf("not implemented")
+}
 
-		var d bool
-		if d, newVal, err = encodeInterfaceType(name, enc, val.Index(0).Interface()); err != nil {
-			return
-		} else if d {
-			newVal = val.Slice(1, val.Len()).Interface()
-		}
+// http://www.w3.org/2001/XMLSchema-datatypes does not have elements.
+func (b baseSchema) GetElement(name string) (*Element, GetAliaser, error) {
+	return nil, nil, fmt.Errorf("not implemented")
+}
 
-		if reflect.ValueOf(newVal).Len() == 0 {
-			del = true
+// http://www.w3.org/2001/XMLSchema-datatypes does not have attributes.
+func (b baseSchema) GetAttribute(name string) (*Attribute, GetAliaser, error) {
+	return nil, nil, fmt.Errorf("not implemented")
+}
+
+// http://www.w3.org/2001/XMLSchema-datatypes does not have model groups.
+func (b baseSchema) GetGroup(name string) (*Group, GetAliaser, error) {
+	return nil, nil, fmt.Errorf("not implemented")
+}
+
+// http://www.w3.org/2001/XMLSchema-datatypes does not have attribute groups.
+func (b baseSchema) EncodeAttributeGroup(name string, enc *Encoder, sr SchemaRepository, params map[string]interface{}, path ...string) ([]xml.Attr, bool, error) {
+	return nil, false, fmt.Errorf("not implemented")
+}
+
+// The built-in types are no SimpleType, they are implemented by the mappings.
+func (b baseSchema) GetSimpleType(name string) (*SimpleType, GetAliaser, error) {
+	return nil, nil, fmt.Errorf("not implemented")
+}
+
+// builtinListItems are the item types of the built-in list types
+var builtinListItems = map[string]string{
+	"NMTOKENS": "NMTOKEN",
+	"IDREFS":   "IDREF",
+	"ENTITIES": "ENTITY",
+}
+
+func (b baseSchema) FormatType(name string, enc *Encoder, sr SchemaRepository, v interface{}, path ...string) (string, error) {
+	if item := builtinListItems[name]; item != "" && isListValue(v) {
+		val := reflect.ValueOf(v)
+		items := make([]string, val.Len())
+		for i := range items {
+			s, err := convert(item, val.Index(i).Interface())
+			if err != nil {
+				return "", err
+			}
+			items[i] = s
 		}
-		return
+
+		v = strings.Join(items, " ")
 	}
 
+	return convert(name, v)
+}
+
+// formatKind formats v by the mapping of its kind for the xsd base type name. Values outside of the lexical or
+// value space of the type are reported as invalidValueError.
+func formatKind(name string, v reflect.Value) (string, error) {
+	var known bool
 	for _, m := range mappings {
 		for _, n := range m.xsdSchema {
-			if n == name {
-				for _, t := range m.kinds {
-					if t == val.Kind() {
-						del = true
-						err = enc.EncodeToken(xml.CharData(fmt.Sprintf(m.format, v)))
-						return
-					}
+			if n != name {
+				continue
+			}
+
+			known = true
+			if hasKind(m.kinds, v.Kind()) {
+				s := m.format(v)
+				if m.lexical == nil {
+					return s, nil
 				}
+				return m.lexical(name, s)
 			}
 		}
 	}
 
-	err = fmt.Errorf("no mapping found for xsd base type %s and kind %s", name, val.Kind())
-	return
+	if known {
+		return "", invalidValue("value of type %s can't be converted to xs:%s", v.Type(), name)
+	}
+
+	return "", fmt.Errorf("no mapping found for xsd base type %s and kind %s", name, v.Kind())
+}
+
+func formatBool(v reflect.Value) string {
+	return strconv.FormatBool(v.Bool())
+}
+
+func formatInt(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10)
+	}
+
+	return strconv.FormatInt(v.Int(), 10)
+}
+
+// formatFloat formats the shortest representation which parses back to the same float
+func formatFloat(v reflect.Value) string {
+	return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits())
+}
+
+// formatDecimal is formatFloat without exponent, which xs:decimal does not allow
+func formatDecimal(v reflect.Value) string {
+	return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits())
+}
+
+func formatString(v reflect.Value) string {
+	return v.String()
+}
+
+func formatAny(v reflect.Value) string {
+	return fmt.Sprint(v.Interface())
 }
@@ -1,9 +1,8 @@
 package xsd
 
 import (
-	"errors"
 	"fmt"
-	"strings"
+	"strconv"
 
 	"github.com/sezzle/sezzle-go-xml"
 )
@@ -16,43 +15,242 @@ type ComplexType struct {
 	Choice         []Element       `xml:"choice>element"`          // Allows only one or zero of the elements contained int the declaration to be present within the containing element
 	SequenceChoice []Element       `xml:"sequence>choice>element"` // Allows only one or zero of the elements contained int the declaration to be present within the containing element
 	Content        *ComplexContent `xml:"http://www.w3.org/2001/XMLSchema complexContent"`
-	// TODO: By XML definition, a choice will require one of the elements unless the choice block's minOccurs is 0. We're not currently handling this and just allowing no choice blocks to be submitted
-	// TODO: All   - Missing the <xs:all schema component, which specifies that the child elements can appear in any order.
-	// TODO: Group - Missing <xs:group schema component (remove me - see DescribeVpcAttributesGroup)
-	// TODO: Does not support choice>sequence>elements nested schemas
+	SimpleContent  *SimpleContent  `xml:"http://www.w3.org/2001/XMLSchema simpleContent"`
+
+	// Particle is the content model of the type as a tree of sequences, choices, alls, groups, elements and anys.
+	// Sequence, Choice and SequenceChoice are views of its most common shapes, the type is encoded by Particle.
+	Particle *Particle `xml:"-"`
+
+	Attributes      []Attribute      `xml:"http://www.w3.org/2001/XMLSchema attribute"`
+	AttributeGroups []AttributeGroup `xml:"http://www.w3.org/2001/XMLSchema attributeGroup"`
+	AnyAttribute    *AnyAttribute    `xml:"http://www.w3.org/2001/XMLSchema anyAttribute"`
 }
 
+// ComplexContent derives a complex type with a content model from its base, either by extension or by restriction
 type ComplexContent struct {
-	XMLName   xml.Name  `xml:"http://www.w3.org/2001/XMLSchema complexContent"`
-	Extension Extension `xml:"http://www.w3.org/2001/XMLSchema extension"`
+	XMLName     xml.Name     `xml:"http://www.w3.org/2001/XMLSchema complexContent"`
+	Extension   Extension    `xml:"http://www.w3.org/2001/XMLSchema extension"`
+	Restriction *Restriction `xml:"http://www.w3.org/2001/XMLSchema restriction"`
+}
+
+// SimpleContent derives a complex type whose content is a simple value, like an amount with a currency attribute.
+// An extension adds attributes to a simple type or a complex type with simple content, a restriction constrains the
+// value of a complex type with simple content.
+type SimpleContent struct {
+	XMLName     xml.Name     `xml:"http://www.w3.org/2001/XMLSchema simpleContent"`
+	Extension   *Extension   `xml:"http://www.w3.org/2001/XMLSchema extension"`
+	Restriction *Restriction `xml:"http://www.w3.org/2001/XMLSchema restriction"`
 }
 
 type Extension struct {
-	XMLName  xml.Name  `xml:"http://www.w3.org/2001/XMLSchema extension"`
-	Base     string    `xml:"base,attr"`
-	Sequence []Element `xml:"sequence>element"`
+	XMLName         xml.Name         `xml:"http://www.w3.org/2001/XMLSchema extension"`
+	Base            string           `xml:"base,attr"`
+	Sequence        []Element        `xml:"sequence>element"`
+	Particle        *Particle        `xml:"-"` // The content appended to the content of the base type
+	Attributes      []Attribute      `xml:"http://www.w3.org/2001/XMLSchema attribute"`
+	AttributeGroups []AttributeGroup `xml:"http://www.w3.org/2001/XMLSchema attributeGroup"`
+	AnyAttribute    *AnyAttribute    `xml:"http://www.w3.org/2001/XMLSchema anyAttribute"`
 }
 
-func (c *ComplexType) Encode(enc *xml.Encoder, sr SchemaRepository, ga GetAliaser, params map[string]interface{}, useNamespace, keepUsingNamespace bool, path ...string) error {
-	for _, e := range c.Sequence {
-		err := e.Encode(enc, sr, ga, params, useNamespace, keepUsingNamespace, path...)
-		if err != nil {
+// Restriction derives a complex type by restricting its base. A restriction of complexContent restates the whole
+// content model in Particle, one of simpleContent constrains the value of its base by the facets of Facets. Attributes
+// of the base are inherited, unless they are declared again.
+type Restriction struct {
+	XMLName         xml.Name              `xml:"http://www.w3.org/2001/XMLSchema restriction"`
+	Base            string                `xml:"base,attr"`
+	Particle        *Particle             `xml:"-"`
+	Facets          SimpleTypeRestriction `xml:"-"`
+	Attributes      []Attribute           `xml:"http://www.w3.org/2001/XMLSchema attribute"`
+	AttributeGroups []AttributeGroup      `xml:"http://www.w3.org/2001/XMLSchema attributeGroup"`
+	AnyAttribute    *AnyAttribute         `xml:"http://www.w3.org/2001/XMLSchema anyAttribute"`
+}
+
+// UnmarshalXML decodes the content model into a tree of particles, since nested compositors can't be read in
+// document order with struct tags
+func (c *ComplexType) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	c.XMLName = start.Name
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "name":
+			c.Name = attr.Value
+		case "abstract":
+			c.Abstract, _ = strconv.ParseBool(attr.Value)
+		}
+	}
+
+	err := decodeChildren(d, func(child xml.StartElement) error {
+		switch child.Name.Local {
+		case SequenceParticle, ChoiceParticle, AllParticle, GroupParticle:
+			p, err := decodeParticle(d, child)
+			c.Particle = &p
 			return err
+		case "complexContent":
+			c.Content = new(ComplexContent)
+			return d.DecodeElement(c.Content, &child)
+		case "simpleContent":
+			c.SimpleContent = new(SimpleContent)
+			return d.DecodeElement(c.SimpleContent, &child)
+		default:
+			return decodeAttributes(d, child, &c.Attributes, &c.AttributeGroups, &c.AnyAttribute)
+		}
+	})
+	if err != nil || c.Particle == nil {
+		return err
+	}
+
+	switch c.Particle.Kind {
+	case SequenceParticle:
+		c.Sequence = c.Particle.elements()
+		for _, p := range c.Particle.Particles {
+			if p.Kind == ChoiceParticle {
+				c.SequenceChoice = append(c.SequenceChoice, p.elements()...)
+			}
 		}
+	case ChoiceParticle:
+		c.Choice = c.Particle.elements()
 	}
 
-	err := c.EncodeChoice(c.Choice, enc, sr, ga, params, useNamespace, keepUsingNamespace, path...)
-	if err != nil {
+	return nil
+}
+
+// UnmarshalXML decodes the content of the extension into a Particle, like the one of a ComplexType
+func (e *Extension) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	e.XMLName = start.Name
+	e.Base = attrValue(start, "base")
+
+	err := decodeChildren(d, func(child xml.StartElement) error {
+		switch child.Name.Local {
+		case SequenceParticle, ChoiceParticle, AllParticle, GroupParticle:
+			p, err := decodeParticle(d, child)
+			e.Particle = &p
+			return err
+		default:
+			return decodeAttributes(d, child, &e.Attributes, &e.AttributeGroups, &e.AnyAttribute)
+		}
+	})
+	if err != nil || e.Particle == nil {
 		return err
 	}
 
-	err = c.EncodeChoice(c.SequenceChoice, enc, sr, ga, params, useNamespace, keepUsingNamespace, path...)
-	if err != nil {
+	if e.Particle.Kind == SequenceParticle {
+		e.Sequence = e.Particle.elements()
+	}
+
+	return nil
+}
+
+// UnmarshalXML decodes the content model of a complexContent restriction into a Particle, and the facets of a
+// simpleContent restriction into Facets
+func (r *Restriction) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	r.XMLName = start.Name
+	r.Base = attrValue(start, "base")
+
+	facets := map[string]**Facet{
+		"length":         &r.Facets.Length,
+		"minLength":      &r.Facets.MinLength,
+		"maxLength":      &r.Facets.MaxLength,
+		"minInclusive":   &r.Facets.MinInclusive,
+		"maxInclusive":   &r.Facets.MaxInclusive,
+		"minExclusive":   &r.Facets.MinExclusive,
+		"maxExclusive":   &r.Facets.MaxExclusive,
+		"totalDigits":    &r.Facets.TotalDigits,
+		"fractionDigits": &r.Facets.FractionDigits,
+		"whiteSpace":     &r.Facets.WhiteSpace,
+	}
+
+	return decodeChildren(d, func(child xml.StartElement) error {
+		if facet, ok := facets[child.Name.Local]; ok {
+			*facet = new(Facet)
+			return d.DecodeElement(*facet, &child)
+		}
+
+		switch child.Name.Local {
+		case SequenceParticle, ChoiceParticle, AllParticle, GroupParticle:
+			p, err := decodeParticle(d, child)
+			r.Particle = &p
+			return err
+		case "simpleType":
+			r.Facets.SimpleType = new(SimpleType)
+			return d.DecodeElement(r.Facets.SimpleType, &child)
+		case "enumeration":
+			var e Enumeration
+			err := d.DecodeElement(&e, &child)
+			r.Facets.Enumerations = append(r.Facets.Enumerations, e)
+			return err
+		case "pattern":
+			var f Facet
+			err := d.DecodeElement(&f, &child)
+			r.Facets.Patterns = append(r.Facets.Patterns, f)
+			return err
+		default:
+			return decodeAttributes(d, child, &r.Attributes, &r.AttributeGroups, &r.AnyAttribute)
+		}
+	})
+}
+
+// decodeAttributes decodes child if it is an attribute declaration, and skips it otherwise
+func decodeAttributes(d *xml.Decoder, child xml.StartElement, attributes *[]Attribute, groups *[]AttributeGroup, anyAttribute **AnyAttribute) error {
+	switch child.Name.Local {
+	case "attribute":
+		var a Attribute
+		err := d.DecodeElement(&a, &child)
+		*attributes = append(*attributes, a)
 		return err
+	case "attributeGroup":
+		var g AttributeGroup
+		err := d.DecodeElement(&g, &child)
+		*groups = append(*groups, g)
+		return err
+	case "anyAttribute":
+		*anyAttribute = new(AnyAttribute)
+		return d.DecodeElement(*anyAttribute, &child)
+	}
+
+	return d.Skip()
+}
+
+// elementNames returns the names of all elements of the content model, including those of the extension
+func (c *ComplexType) elementNames(sr SchemaRepository, ga GetAliaser) []string {
+	var names []string
+	if c.Particle != nil {
+		names = c.Particle.ElementNames(sr, ga)
+	}
+
+	if c.Content != nil && c.Content.Extension.Particle != nil {
+		names = append(names, c.Content.Extension.Particle.ElementNames(sr, ga)...)
+	}
+
+	if c.Content != nil && c.Content.Restriction != nil && c.Content.Restriction.Particle != nil {
+		names = append(names, c.Content.Restriction.Particle.ElementNames(sr, ga)...)
+	}
+
+	return names
+}
+
+func (c *ComplexType) Encode(enc *Encoder, sr SchemaRepository, ga GetAliaser, params map[string]interface{}, path ...string) error {
+	if c.SimpleContent != nil {
+		return c.SimpleContent.Encode(enc, sr, ga, params, path...)
+	}
+
+	defer enc.declare(c.elementNames(sr, ga))()
+
+	if c.Particle != nil {
+		err := c.Particle.Encode(enc, sr, ga, params, path...)
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.Content != nil && c.Content.Restriction != nil {
+		// A restriction restates the whole content model, so nothing is encoded by its base
+		if p := c.Content.Restriction.Particle; p != nil {
+			return p.Encode(enc, sr, ga, params, path...)
+		}
+		return nil
 	}
 
 	if c.Content != nil {
-		parts := strings.Split(c.Content.Extension.Base, ":")
+		parts := splitQName(c.Content.Extension.Base)
 		switch len(parts) {
 		case 2:
 			var schema Schemaer
@@ -61,7 +259,7 @@ func (c *ComplexType) Encode(enc *xml.Encoder, sr SchemaRepository, ga GetAliase
 				return err
 			}
 
-			err = schema.EncodeType(parts[1], enc, sr, params, keepUsingNamespace, keepUsingNamespace, path...)
+			err = schema.EncodeType(parts[1], enc, sr, params, path...)
 			if err != nil {
 				return err
 			}
@@ -70,8 +268,8 @@ func (c *ComplexType) Encode(enc *xml.Encoder, sr SchemaRepository, ga GetAliase
 			return err
 		}
 
-		for _, e := range c.Content.Extension.Sequence {
-			err := e.Encode(enc, sr, ga, params, useNamespace, keepUsingNamespace, path...)
+		if p := c.Content.Extension.Particle; p != nil {
+			err := p.Encode(enc, sr, ga, params, path...)
 			if err != nil {
 				return err
 			}
@@ -81,32 +279,82 @@ func (c *ComplexType) Encode(enc *xml.Encoder, sr SchemaRepository, ga GetAliase
 	return nil
 }
 
-func (c *ComplexType) EncodeChoice(choiceElements []Element, enc *xml.Encoder, sr SchemaRepository, ga GetAliaser, params map[string]interface{}, useNamespace, keepUsingNamespace bool, path ...string) error {
-	// First, verify that one and only one of the choices for this path has been submitted on the params
-	// If none, continue do not encode
-	// If more than one, return error
-	// If one, start encoding - if any of the child element types are also choices, they will need to meet the same criteria
-	submittedChoices := 0
-	for _, e := range choiceElements {
-		if hasPrefix(params, MakePath(append(path, e.Name))) {
-			submittedChoices++
-		}
+// EncodeAttributes : Returns the attributes of the complex type for the element at path, including those of the
+// base type of a complexContent or simpleContent derivation, and whether any other attribute is allowed
+func (c *ComplexType) EncodeAttributes(enc *Encoder, sr SchemaRepository, ga GetAliaser, params map[string]interface{}, path ...string) ([]xml.Attr, bool, error) {
+	attrs, anyAttribute, err := encodeAttributes(enc, sr, ga, c.Attributes, c.AttributeGroups, c.AnyAttribute, params, path...)
+	if err != nil {
+		return nil, false, err
 	}
 
-	if submittedChoices > 1 {
-		return errors.New("A max of one choice element can be submitted")
+	var derivedAttrs []xml.Attr
+	var derivedAny bool
+	switch {
+	case c.Content != nil && c.Content.Restriction != nil:
+		derivedAttrs, derivedAny, err = c.Content.Restriction.EncodeAttributes(enc, sr, ga, params, path...)
+	case c.Content != nil:
+		derivedAttrs, derivedAny, err = c.Content.Extension.EncodeAttributes(enc, sr, ga, params, path...)
+	case c.SimpleContent != nil && c.SimpleContent.Restriction != nil:
+		derivedAttrs, derivedAny, err = c.SimpleContent.Restriction.EncodeAttributes(enc, sr, ga, params, path...)
+	case c.SimpleContent != nil && c.SimpleContent.Extension != nil:
+		derivedAttrs, derivedAny, err = c.SimpleContent.Extension.EncodeAttributes(enc, sr, ga, params, path...)
+	}
+	if err != nil {
+		return nil, false, err
 	}
 
-	if submittedChoices == 1 {
-		for _, e := range choiceElements {
-			if hasPrefix(params, MakePath(append(path, e.Name))) {
-				err := e.Encode(enc, sr, ga, params, useNamespace, keepUsingNamespace, path...)
-				if err != nil {
-					return err
-				}
-			}
-		}
+	return append(derivedAttrs, attrs...), anyAttribute || derivedAny, nil
+}
+
+// EncodeAttributes : Returns the attributes of the base type followed by those of the extension
+func (e *Extension) EncodeAttributes(enc *Encoder, sr SchemaRepository, ga GetAliaser, params map[string]interface{}, path ...string) ([]xml.Attr, bool, error) {
+	baseAttrs, baseAny, err := encodeBaseAttributes(enc, sr, ga, e.Base, params, path...)
+	if err != nil {
+		return nil, false, err
 	}
 
-	return nil
+	extAttrs, extAny, err := encodeAttributes(enc, sr, ga, e.Attributes, e.AttributeGroups, e.AnyAttribute, params, path...)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return append(baseAttrs, extAttrs...), baseAny || extAny, nil
+}
+
+// EncodeAttributes : Returns the attributes inherited from the base type which the restriction does not declare again,
+// followed by those of the restriction. Whether any other attribute is allowed is up to the restriction alone.
+func (r *Restriction) EncodeAttributes(enc *Encoder, sr SchemaRepository, ga GetAliaser, params map[string]interface{}, path ...string) ([]xml.Attr, bool, error) {
+	names := make([]string, len(r.Attributes))
+	for i := range r.Attributes {
+		names[i] = r.Attributes[i].localName()
+	}
+
+	endRestrict := enc.restrict(names)
+	baseAttrs, _, err := encodeBaseAttributes(enc, sr, ga, r.Base, params, path...)
+	endRestrict()
+	if err != nil {
+		return nil, false, err
+	}
+
+	attrs, anyAttribute, err := encodeAttributes(enc, sr, ga, r.Attributes, r.AttributeGroups, r.AnyAttribute, params, path...)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return append(baseAttrs, attrs...), anyAttribute, nil
+}
+
+// encodeBaseAttributes returns the attributes of the base type of a derivation, a qualified name
+func encodeBaseAttributes(enc *Encoder, sr SchemaRepository, ga GetAliaser, base string, params map[string]interface{}, path ...string) ([]xml.Attr, bool, error) {
+	parts := splitQName(base)
+	if len(parts) != 2 {
+		return nil, false, fmt.Errorf("malformed base '%s' in path %q", base, path)
+	}
+
+	schema, err := sr.GetSchema(ga.GetAlias(parts[0]))
+	if err != nil {
+		return nil, false, err
+	}
+
+	return schema.EncodeTypeAttributes(parts[1], enc, sr, params, path...)
 }
@@ -0,0 +1,235 @@
+package xsd
+
+import (
+	"encoding"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Converter returns the lexical representation of v for the xsd built-in type name, like "dateTime" or "int". The
+// result is checked against the lexical space of the type afterwards, errors are reported as validation errors of
+// the path the value was submitted for.
+type Converter func(name string, v interface{}) (string, error)
+
+var converters = struct {
+	sync.RWMutex
+	types map[reflect.Type]Converter
+}{
+	types: map[reflect.Type]Converter{
+		reflect.TypeOf(time.Time{}):      convertTime,
+		reflect.TypeOf([]byte{}):         convertBytes,
+		reflect.TypeOf(&big.Int{}):       convertBigInt,
+		reflect.TypeOf(&big.Float{}):     convertBigFloat,
+		reflect.TypeOf(time.Duration(0)): convertDuration,
+	},
+}
+
+// RegisterConverter : Registers c for the values of the type of v, replacing the converter registered before.
+// Converters are looked up by the exact type of a param value, before the value is formatted by its kind.
+//
+//	xsd.RegisterConverter(Money{}, func(name string, v interface{}) (string, error) {
+//		return v.(Money).String(), nil
+//	})
+func RegisterConverter(v interface{}, c Converter) {
+	converters.Lock()
+	defer converters.Unlock()
+
+	converters.types[reflect.TypeOf(v)] = c
+}
+
+func converter(t reflect.Type) (Converter, bool) {
+	converters.RLock()
+	defer converters.RUnlock()
+
+	c, ok := converters.types[t]
+	return c, ok
+}
+
+// isScalar reports whether values of t are a single value even though their kind would be split up, like a []byte
+// or a type with a converter
+func isScalar(t reflect.Type) bool {
+	if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+		return true
+	}
+
+	_, ok := converter(t)
+	return ok
+}
+
+// convert returns the lexical representation of v for the xsd base type name by a registered converter, the
+// encoding.TextMarshaler of v or the mapping of its kind, following pointers.
+func convert(name string, v interface{}) (string, error) {
+	if v == nil {
+		return "", invalidValue("nil is not a valid xs:%s", name)
+	}
+
+	if c, ok := converter(reflect.TypeOf(v)); ok {
+		s, err := c(name, v)
+		if err != nil {
+			if _, ok := err.(invalidValueError); !ok {
+				err = invalidValue("%s", err)
+			}
+			return "", err
+		}
+
+		return checkLexical(name, s)
+	}
+
+	if m, ok := v.(encoding.TextMarshaler); ok {
+		if val := reflect.ValueOf(v); val.Kind() == reflect.Ptr && val.IsNil() {
+			return "", invalidValue("nil is not a valid xs:%s", name)
+		}
+
+		text, err := m.MarshalText()
+		if err != nil {
+			return "", invalidValue("%s", err)
+		}
+
+		return checkLexical(name, string(text))
+	}
+
+	val := reflect.ValueOf(v)
+	if val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return "", invalidValue("nil is not a valid xs:%s", name)
+		}
+
+		return convert(name, val.Elem().Interface())
+	}
+
+	return formatKind(name, val)
+}
+
+// formatAnyValue formats a value of an element or attribute which is not declared by the schema. Values no
+// converter or mapping exists for are written by their default format.
+func formatAnyValue(v interface{}) string {
+	s, err := convert("anySimpleType", v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+
+	return s
+}
+
+// checkLexical checks s against the lexical space of the xsd base type name
+func checkLexical(name, s string) (string, error) {
+	for _, m := range mappings {
+		for _, n := range m.xsdSchema {
+			if n == name && hasKind(m.kinds, reflect.String) {
+				if m.lexical == nil {
+					return s, nil
+				}
+				return m.lexical(name, s)
+			}
+		}
+	}
+
+	return "", fmt.Errorf("no mapping found for xsd base type %s", name)
+}
+
+func hasKind(kinds []reflect.Kind, kind reflect.Kind) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+
+	return false
+}
+
+// timeLayouts are the layouts of the date and time types a time.Time can be formatted as
+var timeLayouts = map[string]string{
+	"dateTime":   time.RFC3339Nano,
+	"date":       "2006-01-02Z07:00",
+	"time":       "15:04:05.999999999Z07:00",
+	"gYearMonth": "2006-01Z07:00",
+	"gYear":      "2006Z07:00",
+	"gMonthDay":  "--01-02Z07:00",
+	"gMonth":     "--01Z07:00",
+	"gDay":       "---02Z07:00",
+}
+
+func convertTime(name string, v interface{}) (string, error) {
+	t := v.(time.Time)
+	if layout, ok := timeLayouts[name]; ok {
+		return t.Format(layout), nil
+	}
+
+	switch name {
+	case "string", "anySimpleType", "anyType":
+		return t.Format(time.RFC3339Nano), nil
+	}
+
+	return "", invalidValue("time.Time can't be converted to xs:%s", name)
+}
+
+// convertDuration formats a time.Duration as xs:duration, other types get its number of nanoseconds
+func convertDuration(name string, v interface{}) (string, error) {
+	d := v.(time.Duration)
+	if name != "duration" {
+		return formatKind(name, reflect.ValueOf(int64(d)))
+	}
+
+	s := "PT"
+	if d < 0 {
+		s, d = "-PT", -d
+	}
+
+	if h := d / time.Hour; h > 0 {
+		s += fmt.Sprintf("%dH", h)
+		d -= h * time.Hour
+	}
+	if m := d / time.Minute; m > 0 {
+		s += fmt.Sprintf("%dM", m)
+		d -= m * time.Minute
+	}
+	if d > 0 || s == "PT" || s == "-PT" {
+		s += strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "S"
+	}
+
+	return s, nil
+}
+
+func convertBytes(name string, v interface{}) (string, error) {
+	b := v.([]byte)
+	switch name {
+	case "hexBinary":
+		return hex.EncodeToString(b), nil
+	case "base64Binary", "anySimpleType", "anyType":
+		return base64.StdEncoding.EncodeToString(b), nil
+	}
+
+	return string(b), nil
+}
+
+func convertBigInt(name string, v interface{}) (string, error) {
+	i := v.(*big.Int)
+	if i == nil {
+		return "", invalidValue("nil is not a valid xs:%s", name)
+	}
+
+	return i.String(), nil
+}
+
+func convertBigFloat(name string, v interface{}) (string, error) {
+	f := v.(*big.Float)
+	switch {
+	case f == nil:
+		return "", invalidValue("nil is not a valid xs:%s", name)
+	case f.IsInf():
+		if name == "float" || name == "double" {
+			return f.String(), nil
+		}
+		return "", invalidValue("value %s is not a valid xs:%s", f, name)
+	case name == "float" || name == "double":
+		return f.Text('g', -1), nil
+	}
+
+	return f.Text('f', -1), nil
+}
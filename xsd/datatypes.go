@@ -0,0 +1,237 @@
+package xsd
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// invalidValueError is a value outside of the lexical or value space of an xsd type. It is reported as validation
+// error of the path the value was submitted for, instead of aborting the request.
+type invalidValueError struct {
+	msg string
+}
+
+func (e invalidValueError) Error() string {
+	return e.msg
+}
+
+func invalidValue(format string, args ...interface{}) error {
+	return invalidValueError{msg: fmt.Sprintf(format, args...)}
+}
+
+// invalidValue : Records err as validation error of path if it is an invalidValueError and reports whether it did
+func (enc *Encoder) invalidValue(path []string, err error) bool {
+	if iv, ok := err.(invalidValueError); ok {
+		enc.Invalid(path, "%s", iv.msg)
+		return true
+	}
+
+	return false
+}
+
+// integerBounds are the inclusive value spaces of the integer types derived from xs:integer, nil is unbounded
+var integerBounds = map[string][2]*big.Int{
+	"long":               {bigInt("-9223372036854775808"), bigInt("9223372036854775807")},
+	"int":                {bigInt("-2147483648"), bigInt("2147483647")},
+	"short":              {bigInt("-32768"), bigInt("32767")},
+	"byte":               {bigInt("-128"), bigInt("127")},
+	"nonNegativeInteger": {bigInt("0"), nil},
+	"positiveInteger":    {bigInt("1"), nil},
+	"nonPositiveInteger": {nil, bigInt("0")},
+	"negativeInteger":    {nil, bigInt("-1")},
+	"unsignedLong":       {bigInt("0"), bigInt("18446744073709551615")},
+	"unsignedInt":        {bigInt("0"), bigInt("4294967295")},
+	"unsignedShort":      {bigInt("0"), bigInt("65535")},
+	"unsignedByte":       {bigInt("0"), bigInt("255")},
+}
+
+func bigInt(s string) *big.Int {
+	i, _ := new(big.Int).SetString(s, 10)
+	return i
+}
+
+var (
+	integerPattern = regexp.MustCompile(`^[+-]?[0-9]+$`)
+	decimalPattern = regexp.MustCompile(`^[+-]?([0-9]+(\.[0-9]*)?|\.[0-9]+)$`)
+	floatPattern   = regexp.MustCompile(`^([+-]?([0-9]+(\.[0-9]*)?|\.[0-9]+)([eE][+-]?[0-9]+)?|-?INF|NaN)$`)
+	hexPattern     = regexp.MustCompile(`^([0-9a-fA-F]{2})*$`)
+
+	languagePattern = regexp.MustCompile(`^[a-zA-Z]{1,8}(-[a-zA-Z0-9]{1,8})*$`)
+	namePattern     = regexp.MustCompile(`^[\p{L}_:][\p{L}\p{N}\p{Mn}\p{Mc}._:\-]*$`)
+	ncNamePattern   = regexp.MustCompile(`^[\p{L}_][\p{L}\p{N}\p{Mn}\p{Mc}._\-]*$`)
+	nmtokenPattern  = regexp.MustCompile(`^[\p{L}\p{N}\p{Mn}\p{Mc}._:\-]+$`)
+	qNamePattern    = regexp.MustCompile(`^([\p{L}_][\p{L}\p{N}\p{Mn}\p{Mc}._\-]*:)?[\p{L}_][\p{L}\p{N}\p{Mn}\p{Mc}._\-]*$`)
+)
+
+const (
+	yearPattern     = `-?([1-9][0-9]{3,}|0[0-9]{3})`
+	monthPattern    = `(0[1-9]|1[0-2])`
+	dayPattern      = `(0[1-9]|[12][0-9]|3[01])`
+	timePattern     = `(([01][0-9]|2[0-3]):[0-5][0-9]:[0-5][0-9](\.[0-9]+)?|24:00:00(\.0+)?)`
+	timezonePattern = `(Z|[+-]((0[0-9]|1[0-3]):[0-5][0-9]|14:00))?`
+)
+
+// datePatterns are the lexical spaces of the date and time types
+var datePatterns = map[string]*regexp.Regexp{
+	"dateTime":   regexp.MustCompile(`^` + yearPattern + `-` + monthPattern + `-` + dayPattern + `T` + timePattern + timezonePattern + `$`),
+	"date":       regexp.MustCompile(`^` + yearPattern + `-` + monthPattern + `-` + dayPattern + timezonePattern + `$`),
+	"time":       regexp.MustCompile(`^` + timePattern + timezonePattern + `$`),
+	"gYearMonth": regexp.MustCompile(`^` + yearPattern + `-` + monthPattern + timezonePattern + `$`),
+	"gYear":      regexp.MustCompile(`^` + yearPattern + timezonePattern + `$`),
+	"gMonthDay":  regexp.MustCompile(`^--` + monthPattern + `-` + dayPattern + timezonePattern + `$`),
+	"gDay":       regexp.MustCompile(`^---` + dayPattern + timezonePattern + `$`),
+	"gMonth":     regexp.MustCompile(`^--` + monthPattern + timezonePattern + `$`),
+	"duration":   regexp.MustCompile(`^-?P(([0-9]+Y)?([0-9]+M)?([0-9]+D)?(T([0-9]+H)?([0-9]+M)?([0-9]+(\.[0-9]+)?S)?)?)$`),
+}
+
+func lexicalBoolean(name, s string) (string, error) {
+	switch s = strings.TrimSpace(s); s {
+	case "true", "false", "1", "0":
+		return s, nil
+	}
+
+	return "", invalidValue("value %q is not a valid xs:%s", s, name)
+}
+
+func lexicalInteger(name, s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if !integerPattern.MatchString(s) {
+		return "", invalidValue("value %q is not a valid xs:%s", s, name)
+	}
+
+	i := bigInt(strings.TrimPrefix(s, "+"))
+	bounds := integerBounds[name]
+	if bounds[0] != nil && i.Cmp(bounds[0]) < 0 || bounds[1] != nil && i.Cmp(bounds[1]) > 0 {
+		return "", invalidValue("value %s is out of the range of xs:%s", s, name)
+	}
+
+	return i.String(), nil
+}
+
+func lexicalDecimal(name, s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if !decimalPattern.MatchString(s) {
+		return "", invalidValue("value %q is not a valid xs:%s", s, name)
+	}
+
+	return s, nil
+}
+
+func lexicalFloat(name, s string) (string, error) {
+	switch s = strings.TrimSpace(s); s {
+	case "+Inf":
+		return "INF", nil
+	case "-Inf":
+		return "-INF", nil
+	}
+
+	if !floatPattern.MatchString(s) {
+		return "", invalidValue("value %q is not a valid xs:%s", s, name)
+	}
+
+	return s, nil
+}
+
+// lexicalString applies the whitespace handling of the string types derived from xs:normalizedString and checks
+// the names and tokens against their patterns
+func lexicalString(name, s string) (string, error) {
+	if name == "normalizedString" {
+		return strings.NewReplacer("\t", " ", "\n", " ", "\r", " ").Replace(s), nil
+	}
+
+	s = strings.Join(strings.Fields(s), " ")
+
+	var pattern *regexp.Regexp
+	var list bool
+	switch name {
+	case "token":
+		return s, nil
+	case "language":
+		pattern = languagePattern
+	case "Name":
+		pattern = namePattern
+	case "NCName", "ID", "IDREF", "ENTITY":
+		pattern = ncNamePattern
+	case "IDREFS", "ENTITIES":
+		pattern, list = ncNamePattern, true
+	case "NMTOKEN":
+		pattern = nmtokenPattern
+	case "NMTOKENS":
+		pattern, list = nmtokenPattern, true
+	case "QName", "NOTATION":
+		pattern = qNamePattern
+	}
+
+	tokens := []string{s}
+	if list {
+		tokens = strings.Fields(s)
+		if len(tokens) == 0 {
+			return "", invalidValue("value %q is not a valid xs:%s", s, name)
+		}
+	}
+
+	for _, t := range tokens {
+		if !pattern.MatchString(t) {
+			return "", invalidValue("value %q is not a valid xs:%s", s, name)
+		}
+	}
+
+	return s, nil
+}
+
+func lexicalDate(name, s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if !datePatterns[name].MatchString(s) || name == "duration" && (strings.HasSuffix(s, "P") || strings.HasSuffix(s, "T")) {
+		return "", invalidValue("value %q is not a valid xs:%s", s, name)
+	}
+
+	return s, nil
+}
+
+// lexicalCalendarNumber formats a year, month or day given as number
+func lexicalCalendarNumber(name, s string) (string, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return "", invalidValue("value %q is not a valid xs:%s", s, name)
+	}
+
+	switch {
+	case name == "gYear" && n != 0:
+		if n < 0 {
+			return fmt.Sprintf("-%04d", -n), nil
+		}
+		return fmt.Sprintf("%04d", n), nil
+	case name == "gMonth" && n >= 1 && n <= 12:
+		return fmt.Sprintf("--%02d", n), nil
+	case name == "gDay" && n >= 1 && n <= 31:
+		return fmt.Sprintf("---%02d", n), nil
+	}
+
+	return "", invalidValue("value %d is out of the range of xs:%s", n, name)
+}
+
+func lexicalBinary(name, s string) (string, error) {
+	s = strings.Join(strings.Fields(s), "")
+
+	var err error
+	if name == "hexBinary" {
+		if !hexPattern.MatchString(s) {
+			return "", invalidValue("value %q is not a valid xs:%s", s, name)
+		}
+		_, err = hex.DecodeString(s)
+		s = strings.ToUpper(s)
+	} else {
+		_, err = base64.StdEncoding.DecodeString(s)
+	}
+
+	if err != nil {
+		return "", invalidValue("value %q is not a valid xs:%s", s, name)
+	}
+
+	return s, nil
+}
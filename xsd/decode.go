@@ -0,0 +1,42 @@
+package xsd
+
+import (
+	"github.com/sezzle/sezzle-go-xml"
+)
+
+const schemaNamespace = "http://www.w3.org/2001/XMLSchema"
+
+// decodeChildren calls fn for every child element of the element currently decoded, until its end element is
+// reached. Children outside of the XML Schema namespace are skipped, fn has to decode or skip every other child.
+func decodeChildren(d *xml.Decoder, fn func(xml.StartElement) error) error {
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+
+		switch t := t.(type) {
+		case xml.StartElement:
+			if t.Name.Space != schemaNamespace {
+				err = d.Skip()
+			} else {
+				err = fn(t)
+			}
+			if err != nil {
+				return err
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+func attrValue(start xml.StartElement, name string) string {
+	for _, attr := range start.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+
+	return ""
+}
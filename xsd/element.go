@@ -2,6 +2,8 @@ package xsd
 
 import (
 	"fmt"
+	"reflect"
+	"strconv"
 	"strings"
 
 	"github.com/sezzle/sezzle-go-xml"
@@ -9,6 +11,7 @@ import (
 
 type Element struct {
 	XMLName      xml.Name     `xml:"http://www.w3.org/2001/XMLSchema element"`
+	Ref          string       `xml:"ref,attr"` // A global element, which is used with the occurrences of this one
 	Type         string       `xml:"type,attr"`
 	Nillable     string       `xml:"nillable,attr"`
 	MinOccurs    string       `xml:"minOccurs,attr"`
@@ -16,91 +19,309 @@ type Element struct {
 	Form         string       `xml:"form,attr"`
 	Name         string       `xml:"name,attr"`
 	ComplexTypes *ComplexType `xml:"http://www.w3.org/2001/XMLSchema complexType"`
+	SimpleType   *SimpleType  `xml:"http://www.w3.org/2001/XMLSchema simpleType"`
+
+	// global is set for the top level elements of a schema, which are always qualified
+	global bool
 }
 
-var (
-	envName = "ns0"
-)
+// localName returns the name of the element, or of the global element it references
+func (e *Element) localName() string {
+	if e.Ref != "" {
+		return e.Ref[strings.Index(e.Ref, ":")+1:]
+	}
+
+	return e.Name
+}
 
-func (e *Element) Encode(enc *xml.Encoder, sr SchemaRepository, ga GetAliaser, params map[string]interface{}, useNamespace, keepUsingNamespace bool, path ...string) error {
-	// If minOccurs="0" and the current schema element was not submitted on the parameters, we don't need it
-	// If a value for the current schema element was submitted, continue with encoding
-	if e.MinOccurs == "0" && !hasPrefix(params, MakePath(append(path, e.Name))) {
-		return nil
+// resolveElement returns the global element referenced by ref, and the schema it is defined in
+func resolveElement(ref string, sr SchemaRepository, ga GetAliaser) (*Element, GetAliaser, error) {
+	parts := splitQName(ref)
+	if len(parts) != 2 {
+		return nil, nil, fmt.Errorf("malformed element ref '%s'", ref)
 	}
 
-	var namespace, prefix string
-	if useNamespace {
-		namespace = ga.Namespace()
-		prefix = envName
+	schema, err := sr.GetSchema(ga.GetAlias(parts[0]))
+	if err != nil {
+		return nil, nil, err
 	}
 
-	start := xml.StartElement{
-		Name: xml.Name{
-			Space:  namespace,
-			Prefix: prefix,
-			Local:  e.Name,
-		},
+	return schema.GetElement(parts[1])
+}
+
+// Encode : Encodes every occurrence of the element submitted on the params. Elements with a minOccurs above zero are
+// encoded even without params, so that missing required data below them gets reported.
+func (e *Element) Encode(enc *Encoder, sr SchemaRepository, ga GetAliaser, params map[string]interface{}, path ...string) error {
+	if e.Ref != "" {
+		ref, refSchema, err := resolveElement(e.Ref, sr, ga)
+		if err != nil {
+			return err
+		}
+
+		resolved := *ref
+		resolved.MinOccurs, resolved.MaxOccurs = e.MinOccurs, e.MaxOccurs
+		resolved.global = true
+		return resolved.Encode(enc, sr, refSchema, params, path...)
 	}
 
-	err := enc.EncodeToken(start)
-	if err != nil {
-		return err
+	elementPath := appendPath(path, e.Name)
+	minOccurs, maxOccurs := occurs(e.MinOccurs, e.MaxOccurs)
+	enc.visit(elementPath)
+
+	errs := len(enc.errs)
+
+	if items, ok := params[MakePath(elementPath)].([]map[string]interface{}); ok {
+		delete(params, MakePath(elementPath))
+		return e.encodeItems(enc, sr, ga, items, minOccurs, maxOccurs, path...)
 	}
 
-	// If we've reached a an element with a Type, try to encode the type.
-	// EncodeType will get the cached schema definition from self.Definitions and attempt to encode the type
-	// based on the complexType or simpleType schema definition it has stored.
-	// If the current element itself is an empty ComplexType tag, recursively call Encode until all elements have been encoded
+	var occurrences int
+	for maxOccurs == unbounded || occurrences < maxOccurs {
+		// If minOccurs="0" and the current schema element was not submitted on the parameters, we don't need it
+		// If a value for the current schema element was submitted, continue with encoding. Once an occurrence is done,
+		// only params matching its content start the next one, a misspelled param is left over as unknown.
+		submitted := hasPrefix(params, MakePath(elementPath)) &&
+			(occurrences == 0 || enc.hasDeclaredParam(params, MakePath(elementPath)))
+		if !submitted && (occurrences > 0 || minOccurs == 0) {
+			break
+		}
+
+		consumed := enc.consumed
+		err := e.encodeOccurrence(enc, sr, ga, params, maxOccurs != 1, path...)
+		if err != nil {
+			return err
+		}
+		occurrences++
+
+		// An occurrence which did not consume any params would be repeated forever
+		if !submitted || enc.consumed == consumed {
+			break
+		}
+	}
+
+	if minOccurs > 1 && occurrences < minOccurs {
+		enc.Invalid(elementPath, "element '%s' occurs %d times, but minOccurs is %d", e.Name, occurrences, minOccurs)
+	}
+
+	// Values left below a repeatable parent belong to its next occurrence, so they are only too many at the top.
+	// Leftovers of a child which already reported its own error are not reported again for every parent.
+	if maxOccurs != unbounded && occurrences == maxOccurs && enc.repeated == 0 && len(enc.errs) == errs &&
+		hasRemainingValues(params, MakePath(elementPath)) {
+		enc.Invalid(elementPath, "element '%s' exceeds maxOccurs %d", e.Name, maxOccurs)
+	}
+
+	return nil
+}
+
+// encodeItems encodes one occurrence of the element per item. The paths of an item are relative to the element.
+func (e *Element) encodeItems(enc *Encoder, sr SchemaRepository, ga GetAliaser, items []map[string]interface{}, minOccurs, maxOccurs int, path ...string) error {
+	elementPath := appendPath(path, e.Name)
+	if len(items) < minOccurs {
+		enc.Invalid(elementPath, "element '%s' occurs %d times, but minOccurs is %d", e.Name, len(items), minOccurs)
+	}
+
+	if maxOccurs != unbounded && len(items) > maxOccurs {
+		enc.Invalid(elementPath, "element '%s' exceeds maxOccurs %d", e.Name, maxOccurs)
+		items = items[:maxOccurs]
+	}
+
+	// Values left in an item can't belong to the next occurrence, so maxOccurs is checked below it like at the top
+	repeated := enc.repeated
+	enc.repeated = 0
+	defer func() {
+		enc.repeated = repeated
+	}()
+
+	for _, item := range items {
+		params := map[string]interface{}{}
+		for k, v := range item {
+			if k == "" {
+				params[MakePath(elementPath)] = v
+			} else {
+				params[MakePath(elementPath)+"/"+k] = v
+			}
+		}
+
+		err := e.encodeOccurrence(enc, sr, ga, params, false, path...)
+		if err != nil {
+			return err
+		}
+
+		enc.Unknown(params)
+	}
+
+	return nil
+}
+
+func (e *Element) encodeOccurrence(enc *Encoder, sr SchemaRepository, ga GetAliaser, params map[string]interface{}, repeatable bool, path ...string) error {
+	if repeatable {
+		enc.repeated++
+		defer func() {
+			enc.repeated--
+		}()
+	}
+
+	elementPath := appendPath(path, e.Name)
+	enc.expand(elementPath)
+
+	// Local elements are qualified by their form, or the elementFormDefault of their schema
+	var namespace string
+	if e.global || ga.QualifiesElement(e.Form) {
+		namespace = ga.Namespace()
+	}
+
+	// Get the appropriate schema encoder for the type based on the submitted element name
+	var schema Schemaer
+	var typeName string
 	if e.Type != "" {
-		parts := strings.Split(e.Type, ":")
+		parts := splitQName(e.Type)
 		switch len(parts) {
 		case 2:
-			// Get the appropriate schema encoder for the type based on the submitted element name
-			var schema Schemaer
+			var err error
 			schema, err = sr.GetSchema(ga.GetAlias(parts[0]))
 			if err != nil {
 				return err
 			}
-
-			err = schema.EncodeType(parts[1], enc, sr, params, keepUsingNamespace, keepUsingNamespace, append(path, e.Name)...)
-			if err != nil {
-				return err
-			}
+			typeName = parts[1]
 		default:
-			err = fmt.Errorf("malformed type '%s' in path %q", e.Type, path)
+			err := fmt.Errorf("malformed type '%s' in path %q", e.Type, path)
 			return err
 		}
-	} else if e.ComplexTypes != nil {
-		for _, element := range e.ComplexTypes.Sequence {
-			err = element.Encode(enc, sr, ga, params, keepUsingNamespace, keepUsingNamespace, append(path, e.Name)...)
-			if err != nil {
-				return err
-			}
+	}
+
+	// A type derived from the declared one is selected by the param "element/@xsi:type"
+	var declared qName
+	if schema != nil {
+		declared = qName{space: ga.GetAlias(splitQName(e.Type)[0]), local: typeName}
+	}
+
+	derivedSchema, derived, ok, err := derivedType(enc, sr, ga, declared, params, elementPath...)
+	if err != nil {
+		return err
+	}
+	if ok {
+		schema, typeName = derivedSchema, derived.local
+	}
+
+	// SOAP encoded arrays hold their items as "item" elements, instead of the content declared by their type
+	var item qName
+	var isArray bool
+	if enc.encodingStyle != nil && schema != nil {
+		item, isArray, err = arrayType(schema, typeName)
+		if err != nil {
+			return err
 		}
+	}
 
-		for _, element := range e.ComplexTypes.Choice {
-			// We don't actually need to do any choice validation here, I think. e.Encode will attempt to encode
-			// a type once one is reached, which will either encode the simple type with no validation, or the
-			// complexType with the choice validations
-			err = element.Encode(enc, sr, ga, params, keepUsingNamespace, keepUsingNamespace, append(path, e.Name)...)
-			if err != nil {
-				return err
-			}
+	// Attributes are taken from the params at "element/@name" before anything below the element is encoded
+	var attrs []xml.Attr
+	var anyAttribute bool
+	switch {
+	case isArray:
+	case schema != nil:
+		attrs, anyAttribute, err = schema.EncodeTypeAttributes(typeName, enc, sr, params, elementPath...)
+	case e.ComplexTypes != nil:
+		attrs, anyAttribute, err = e.ComplexTypes.EncodeAttributes(enc, sr, ga, params, elementPath...)
+	}
+	if err != nil {
+		return err
+	}
+
+	if anyAttribute {
+		attrs = append(attrs, encodeAnyAttributes(enc, params, elementPath...)...)
+	}
+
+	// An element submitted as Nil is written without content
+	nilled := isNil(params, MakePath(elementPath))
+	if nilled {
+		takeParam(params, MakePath(elementPath))
+		enc.consumed++
+
+		if nillable, _ := strconv.ParseBool(e.Nillable); !nillable {
+			enc.Invalid(elementPath, "element '%s' is not nillable", e.Name)
+			return nil
 		}
+	}
 
-		for _, element := range e.ComplexTypes.SequenceChoice {
-			// We don't actually need to do any choice validation here, I think. e.Encode will attempt to encode
-			// a type once one is reached, which will either encode the simple type with no validation, or the
-			// complexType with the choice validations
-			err = element.Encode(enc, sr, ga, params, keepUsingNamespace, keepUsingNamespace, append(path, e.Name)...)
-			if err != nil {
-				return err
-			}
+	name, endScope := enc.qualify(namespace, e.Name)
+	defer endScope()
+
+	// Qualified attributes are prefixed once the element declared its own namespace, so they can share it
+	attrs, endAttrScope := qualifyAttrs(enc, attrs)
+	defer endAttrScope()
+
+	encodingAttrs, endEncoding := enc.encodingAttrs()
+	defer endEncoding()
+
+	// SOAP encoding states the type of every value, not just the derived ones
+	switch {
+	case isArray:
+		typeAttrs, endTypeScope := arrayAttrs(enc, item, arrayLength(params, elementPath...))
+		defer endTypeScope()
+		attrs = append(typeAttrs, attrs...)
+	case ok:
+		typeAttrs, endTypeScope := xsiTypeAttrs(enc, derived)
+		defer endTypeScope()
+		attrs = append(typeAttrs, attrs...)
+	case enc.encodingStyle != nil && declared.local != "":
+		typeAttrs, endTypeScope := xsiTypeAttrs(enc, declared)
+		defer endTypeScope()
+		attrs = append(typeAttrs, attrs...)
+	}
+	attrs = append(encodingAttrs, attrs...)
+
+	if nilled {
+		nilAttrs, endNilScope := xsiNilAttrs(enc)
+		defer endNilScope()
+		attrs = append(attrs, nilAttrs...)
+	}
+
+	start := xml.StartElement{
+		Name: name,
+		Attr: attrs,
+	}
+
+	err = enc.EncodeToken(start)
+	if err != nil {
+		return err
+	}
+
+	if nilled {
+		return enc.EncodeToken(start.End())
+	}
+
+	// If we've reached a an element with a Type, try to encode the type.
+	// EncodeType will get the cached schema definition from self.Definitions and attempt to encode the type
+	// based on the complexType or simpleType schema definition it has stored.
+	// If the current element itself is an empty ComplexType tag, recursively call Encode until all elements have been encoded
+	if isArray {
+		err = encodeArray(enc, sr, item, params, elementPath...)
+		if err != nil {
+			return err
+		}
+	} else if schema != nil {
+		err = schema.EncodeType(typeName, enc, sr, params, elementPath...)
+		if err != nil {
+			return err
+		}
+	} else if e.ComplexTypes != nil {
+		err = e.ComplexTypes.Encode(enc, sr, ga, params, elementPath...)
+		if err != nil {
+			return err
+		}
+	} else if e.SimpleType != nil {
+		err = e.SimpleType.Encode(enc, sr, ga, params, elementPath...)
+		if err != nil {
+			return err
 		}
 	}
 
-	// If an error was thrown above while trying to add a choice element that is not required, we won't close the tag here
+	// An element without content, like an EmptyElementType, is submitted with an empty struct
+	if v, ok := params[MakePath(elementPath)]; ok && isEmptyStruct(v) {
+		takeParam(params, MakePath(elementPath))
+		enc.consumed++
+	}
+
 	err = enc.EncodeToken(start.End())
 	if err != nil {
 		return err
@@ -108,3 +329,17 @@ func (e *Element) Encode(enc *xml.Encoder, sr SchemaRepository, ga GetAliaser, p
 
 	return nil
 }
+
+// isEmptyStruct reports whether v is a struct without fields, or a slice of them
+func isEmptyStruct(v interface{}) bool {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return false
+	}
+
+	if t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+
+	return t.Kind() == reflect.Struct && t.NumField() == 0
+}
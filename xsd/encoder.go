@@ -0,0 +1,248 @@
+package xsd
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/sezzle/sezzle-go-xml"
+)
+
+// Encoder wraps the xml.Encoder a request is written to and keeps the state of encoding that single request, like
+// all validation errors found so far
+type Encoder struct {
+	*xml.Encoder
+
+	errs ValidationErrors
+
+	// consumed counts the param values taken so far. Repeated elements are only encoded again as long as every
+	// occurrence consumes params.
+	consumed int
+	// repeated counts the repeatable elements currently being encoded. Values left over below a repeatable element
+	// might still be consumed by its next occurrence.
+	repeated int
+
+	// known holds every element path visited and whether its content has been encoded, unknown the params left
+	// over after encoding
+	known   map[string]bool
+	unknown UnknownParamsError
+
+	// declared holds the names of the elements declared by the content models currently encoded, innermost last
+	declared []map[string]bool
+
+	// prefixes holds the prefix of every namespace used so far, scopes counts the enclosing elements which declare it
+	prefixes map[string]string
+	scopes   map[string]int
+	assigned int
+
+	// restricted holds the names of the attributes declared again by the restrictions whose base is encoded
+	restricted map[string]bool
+
+	// encodingStyle is set for SOAP encoding, inEncoding once the outermost element carrying it has been started
+	encodingStyle *xml.Attr
+	inEncoding    bool
+}
+
+func NewEncoder(enc *xml.Encoder) *Encoder {
+	return &Encoder{Encoder: enc}
+}
+
+// declare pushes the element names of a content model, so xs:any doesn't take params of declared elements
+func (enc *Encoder) declare(names []string) func() {
+	declared := map[string]bool{}
+	for _, name := range names {
+		declared[name] = true
+	}
+	enc.declared = append(enc.declared, declared)
+
+	return func() {
+		enc.declared = enc.declared[:len(enc.declared)-1]
+	}
+}
+
+// restrict hides the attributes names from the attribute declarations of the base of a restriction, which declares
+// them again, until the returned func is called
+func (enc *Encoder) restrict(names []string) func() {
+	restricted := enc.restricted
+	enc.restricted = map[string]bool{}
+	for name := range restricted {
+		enc.restricted[name] = true
+	}
+	for _, name := range names {
+		enc.restricted[name] = true
+	}
+
+	return func() {
+		enc.restricted = restricted
+	}
+}
+
+func (enc *Encoder) declaredNames() map[string]bool {
+	if len(enc.declared) == 0 {
+		return nil
+	}
+
+	return enc.declared[len(enc.declared)-1]
+}
+
+// qualify : Returns the name of the element local in namespace. Every namespace gets its own prefix, ns0, ns1, ... in
+// the order they are used first, which is declared by the outermost element using it. The returned func ends the
+// scope of the declaration, it has to be called when the element ends.
+func (enc *Encoder) qualify(namespace, local string) (xml.Name, func()) {
+	if namespace == "" {
+		return xml.Name{Local: local}, func() {}
+	}
+
+	prefix := enc.prefix(namespace)
+	if enc.scopes[namespace] > 0 {
+		return xml.Name{Prefix: prefix, Local: local}, func() {}
+	}
+
+	enc.scopes[namespace]++
+	return xml.Name{Space: namespace, Prefix: prefix, Local: local}, func() {
+		enc.scopes[namespace]--
+	}
+}
+
+// StartElement : Writes the start of the element local in namespace, which is not declared by a schema, like the
+// operation element of rpc style requests. The returned func writes its end.
+func (enc *Encoder) StartElement(namespace, local string) (func() error, error) {
+	name, endScope := enc.qualify(namespace, local)
+	attrs, endEncoding := enc.encodingAttrs()
+	end := func() {
+		endEncoding()
+		endScope()
+	}
+
+	start := xml.StartElement{Name: name, Attr: attrs}
+	err := enc.EncodeToken(start)
+	if err != nil {
+		end()
+		return nil, err
+	}
+
+	return func() error {
+		defer end()
+		return enc.EncodeToken(start.End())
+	}, nil
+}
+
+// declareNamespace : Returns the prefix of namespace for a qualified attribute or value of an element, and the
+// attribute declaring it if no enclosing element did. The returned func ends the scope of the declaration, it has
+// to be called when the element ends.
+func (enc *Encoder) declareNamespace(namespace string) (string, []xml.Attr, func()) {
+	prefix := enc.prefix(namespace)
+	if enc.scopes[namespace] > 0 {
+		return prefix, nil, func() {}
+	}
+
+	enc.scopes[namespace]++
+	// The xml encoder writes attributes without a value as namespace declarations
+	decl := xml.Attr{Name: xml.Name{Space: namespace, Prefix: prefix, Local: prefix}}
+	return prefix, []xml.Attr{decl}, func() {
+		enc.scopes[namespace]--
+	}
+}
+
+// wellKnownPrefixes are the prefixes of namespaces which are used by convention
+var wellKnownPrefixes = map[string]string{
+	XSINamespace:          "xsi",
+	schemaNamespace:       "xsd",
+	SOAPEncodingNamespace: "soapenc",
+}
+
+// prefix returns the prefix of namespace, assigning the next free one if it has not been used yet
+func (enc *Encoder) prefix(namespace string) string {
+	if enc.prefixes == nil {
+		enc.prefixes = map[string]string{}
+		enc.scopes = map[string]int{}
+	}
+
+	prefix, ok := enc.prefixes[namespace]
+	if !ok {
+		prefix = wellKnownPrefixes[namespace]
+		if prefix == "" {
+			prefix = fmt.Sprintf("ns%d", enc.assigned)
+			enc.assigned++
+		}
+		enc.prefixes[namespace] = prefix
+	}
+
+	return prefix
+}
+
+// Invalid : Records a validation error for the param path and continues encoding, so all errors of a request are
+// reported at once
+func (enc *Encoder) Invalid(path []string, format string, args ...interface{}) {
+	enc.errs = append(enc.errs, ValidationError{
+		Path:    MakePath(path),
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// Err : Returns all validation errors found so far as ValidationErrors, or nil. Unknown params are added to them,
+// since a misspelled param is the most likely cause of a missing element.
+func (enc *Encoder) Err() error {
+	if len(enc.errs) == 0 {
+		return nil
+	}
+
+	errs := append(ValidationErrors{}, enc.errs...)
+	for _, p := range enc.unknown {
+		if p.Known && errs.has(p.Path) {
+			continue
+		}
+
+		errs = append(errs, ValidationError{Path: p.Path, Message: p.message()})
+	}
+
+	return errs
+}
+
+// ValidationError is a violation of the schema by the params submitted for Path
+type ValidationError struct {
+	Path    string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// ValidationErrors holds every ValidationError of a request, in document order
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i := range e {
+		msgs[i] = e[i].Error()
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+func (e ValidationErrors) has(path string) bool {
+	for i := range e {
+		if e[i].Path == path {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasRemainingValues reports whether a param below prefix still holds values of a repeated element
+func hasRemainingValues(params map[string]interface{}, prefix string) bool {
+	for k, v := range params {
+		if k != prefix && !strings.HasPrefix(k, prefix+"/") {
+			continue
+		}
+
+		val := reflect.ValueOf(v)
+		if val.Kind() == reflect.Slice && val.Len() > 0 {
+			return true
+		}
+	}
+
+	return false
+}
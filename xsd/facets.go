@@ -0,0 +1,290 @@
+package xsd
+
+import (
+	"encoding/base64"
+	"log"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+func facetError(s, facet string, limit interface{}) error {
+	return invalidValue("value %q violates the %s facet %v", s, facet, limit)
+}
+
+// check applies the whiteSpace facet of the restriction to s, the lexical representation of a value of the
+// built-in type builtin, and checks the result against all other facets
+func (r *SimpleTypeRestriction) check(builtin, s string) (string, error) {
+	if r.WhiteSpace != nil {
+		switch r.WhiteSpace.Value {
+		case "replace":
+			s = strings.NewReplacer("\t", " ", "\n", " ", "\r", " ").Replace(s)
+		case "collapse":
+			s = strings.Join(strings.Fields(s), " ")
+		}
+	}
+
+	// Patterns of the same restriction are alternatives
+	if len(r.Patterns) > 0 {
+		var patterns []string
+		var matched, checked bool
+		for _, p := range r.Patterns {
+			patterns = append(patterns, p.Value)
+			if re := compilePattern(p.Value); re != nil {
+				checked = true
+				matched = matched || re.MatchString(s)
+			}
+		}
+
+		if checked && !matched {
+			return "", facetError(s, "pattern", strconv.Quote(strings.Join(patterns, "|")))
+		}
+	}
+
+	if len(r.Enumerations) > 0 {
+		var values []string
+		var found bool
+		for _, e := range r.Enumerations {
+			values = append(values, e.Value)
+			if c, ok := compareValues(builtin, s, e.Value); ok && c == 0 || !ok && s == e.Value {
+				found = true
+			}
+		}
+
+		if !found {
+			return "", invalidValue("value %q violates the enumeration facet %q", s, values)
+		}
+	}
+
+	length := valueLength(builtin, s)
+	if r.Length != nil && strconv.Itoa(length) != r.Length.Value {
+		return "", facetError(s, "length", r.Length.Value)
+	}
+	if r.MinLength != nil && !satisfies(length, r.MinLength.Value, func(l, limit int) bool { return l >= limit }) {
+		return "", facetError(s, "minLength", r.MinLength.Value)
+	}
+	if r.MaxLength != nil && !satisfies(length, r.MaxLength.Value, func(l, limit int) bool { return l <= limit }) {
+		return "", facetError(s, "maxLength", r.MaxLength.Value)
+	}
+
+	bounds := []struct {
+		facet string
+		limit *Facet
+		ok    func(c int) bool
+	}{
+		{"minInclusive", r.MinInclusive, func(c int) bool { return c >= 0 }},
+		{"maxInclusive", r.MaxInclusive, func(c int) bool { return c <= 0 }},
+		{"minExclusive", r.MinExclusive, func(c int) bool { return c > 0 }},
+		{"maxExclusive", r.MaxExclusive, func(c int) bool { return c < 0 }},
+	}
+	for _, b := range bounds {
+		if b.limit == nil {
+			continue
+		}
+
+		if c, ok := compareValues(builtin, s, b.limit.Value); ok && !b.ok(c) {
+			return "", facetError(s, b.facet, b.limit.Value)
+		}
+	}
+
+	total, fraction := digits(s)
+	if r.TotalDigits != nil && !satisfies(total, r.TotalDigits.Value, func(d, limit int) bool { return d <= limit }) {
+		return "", facetError(s, "totalDigits", r.TotalDigits.Value)
+	}
+	if r.FractionDigits != nil && !satisfies(fraction, r.FractionDigits.Value, func(d, limit int) bool { return d <= limit }) {
+		return "", facetError(s, "fractionDigits", r.FractionDigits.Value)
+	}
+
+	return s, nil
+}
+
+// satisfies reports whether n is within limit by ok. Malformed limits are ignored.
+func satisfies(n int, limit string, ok func(n, limit int) bool) bool {
+	l, err := strconv.Atoi(strings.TrimSpace(limit))
+	return err != nil || ok(n, l)
+}
+
+// valueLength returns the length of a value as defined by the length facets: octets of binary types, items of list
+// types and characters of everything else
+func valueLength(builtin, s string) int {
+	switch builtin {
+	case "hexBinary":
+		return len(s) / 2
+	case "base64Binary":
+		b, _ := base64.StdEncoding.DecodeString(s)
+		return len(b)
+	case listBuiltin, "NMTOKENS", "IDREFS", "ENTITIES":
+		return len(strings.Fields(s))
+	}
+
+	return utf8.RuneCountInString(s)
+}
+
+// digits returns the number of significant digits of a decimal, and the number of those in its fraction
+func digits(s string) (total, fraction int) {
+	s = strings.TrimLeft(s, "+-")
+	integer, frac := s, ""
+	if i := strings.Index(s, "."); i >= 0 {
+		integer, frac = s[:i], s[i+1:]
+	}
+
+	integer = strings.TrimLeft(integer, "0")
+	frac = strings.TrimRight(frac, "0")
+	return len(integer) + len(frac), len(frac)
+}
+
+// compareValues compares the values a and b of the built-in type builtin in its value space. ok is false if the
+// type is not ordered, or the values can't be compared.
+func compareValues(builtin, a, b string) (c int, ok bool) {
+	switch builtin {
+	case "decimal", "integer", "long", "int", "short", "byte", "nonNegativeInteger", "positiveInteger",
+		"nonPositiveInteger", "negativeInteger", "unsignedLong", "unsignedInt", "unsignedShort", "unsignedByte":
+		x, okA := new(big.Rat).SetString(strings.TrimPrefix(a, "+"))
+		y, okB := new(big.Rat).SetString(strings.TrimPrefix(b, "+"))
+		if !okA || !okB {
+			return 0, false
+		}
+		return x.Cmp(y), true
+	case "float", "double":
+		x, errA := parseFloat(a)
+		y, errB := parseFloat(b)
+		if errA != nil || errB != nil || math.IsNaN(x) || math.IsNaN(y) {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	case "dateTime", "date", "time":
+		x, errA := parseTime(builtin, a)
+		y, errB := parseTime(builtin, b)
+		if errA != nil || errB != nil {
+			return 0, false
+		}
+		switch {
+		case x.Before(y):
+			return -1, true
+		case x.After(y):
+			return 1, true
+		}
+		return 0, true
+	case "gYear", "gYearMonth", "gMonthDay", "gMonth", "gDay":
+		return strings.Compare(a, b), true
+	}
+
+	return 0, false
+}
+
+func parseFloat(s string) (float64, error) {
+	switch s {
+	case "INF":
+		return math.Inf(1), nil
+	case "-INF":
+		return math.Inf(-1), nil
+	}
+
+	return strconv.ParseFloat(s, 64)
+}
+
+// timeFormats are the layouts of the lexical forms of the ordered date and time types, values without timezone are
+// compared as UTC
+var timeFormats = map[string][]string{
+	"dateTime": {"2006-01-02T15:04:05.999999999Z07:00", "2006-01-02T15:04:05.999999999"},
+	"date":     {"2006-01-02Z07:00", "2006-01-02"},
+	"time":     {"15:04:05.999999999Z07:00", "15:04:05.999999999"},
+}
+
+func parseTime(builtin, s string) (t time.Time, err error) {
+	for _, layout := range timeFormats[builtin] {
+		t, err = time.Parse(layout, s)
+		if err == nil {
+			return t, nil
+		}
+	}
+
+	return t, err
+}
+
+// patterns caches the compiled pattern facets, nil for patterns which can't be translated into a regexp
+var patterns sync.Map
+
+// compilePattern translates the XML Schema regular expression pattern into an anchored regexp. Patterns using
+// features regexp does not support, like character class subtraction or Unicode blocks, are logged once and not
+// enforced.
+func compilePattern(pattern string) *regexp.Regexp {
+	if re, ok := patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp)
+	}
+
+	translated, ok := translatePattern(pattern)
+	re, err := regexp.Compile("^(?:" + translated + ")$")
+	if err != nil || !ok {
+		log.Printf("pattern facet %q is not supported and won't be checked", pattern)
+		re = nil
+	}
+
+	patterns.Store(pattern, re)
+	return re
+}
+
+// nameClasses are the multi-character escapes for XML names, as content of a character class
+var nameClasses = map[byte]string{
+	'i': `\p{L}_:`,
+	'c': `\p{L}\p{N}\p{Mn}\p{Mc}._:\-`,
+}
+
+// translatePattern rewrites the parts of an XML Schema regular expression which regexp reads differently: the
+// multi-character escapes for XML names, and ^ and $, which are no anchors in XML Schema. ok is false if the pattern
+// uses a feature which can't be translated.
+func translatePattern(pattern string) (translated string, ok bool) {
+	var b strings.Builder
+	var inClass bool
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			e := pattern[i]
+			class, isName := nameClasses[e|0x20]
+			switch {
+			case !isName:
+				b.WriteByte(c)
+				b.WriteByte(e)
+			case e == 'I' || e == 'C':
+				// A negated escape can't be merged into an enclosing character class
+				if inClass {
+					return "", false
+				}
+				b.WriteString(`[^` + class + `]`)
+			case inClass:
+				b.WriteString(class)
+			default:
+				b.WriteString(`[` + class + `]`)
+			}
+		case c == '[':
+			inClass = true
+			b.WriteByte(c)
+		case c == ']':
+			inClass = false
+			b.WriteByte(c)
+		case c == '-' && inClass && i+1 < len(pattern) && pattern[i+1] == '[':
+			// Character class subtraction
+			return "", false
+		case (c == '^' || c == '$') && !inClass:
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String(), true
+}
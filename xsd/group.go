@@ -0,0 +1,28 @@
+package xsd
+
+import (
+	"github.com/sezzle/sezzle-go-xml"
+)
+
+// Group is the definition of a named model group. Its content is a single sequence, choice or all.
+type Group struct {
+	XMLName  xml.Name
+	Name     string
+	Particle *Particle
+}
+
+// UnmarshalXML decodes the content of the model group into a Particle
+func (g *Group) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	g.XMLName = start.Name
+	g.Name = attrValue(start, "name")
+
+	return decodeChildren(d, func(child xml.StartElement) error {
+		switch child.Name.Local {
+		case SequenceParticle, ChoiceParticle, AllParticle:
+			p, err := decodeParticle(d, child)
+			g.Particle = &p
+			return err
+		}
+		return d.Skip()
+	})
+}
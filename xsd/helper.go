@@ -1,28 +1,50 @@
 package xsd
 
 import (
+	"strconv"
 	"strings"
 
 	"github.com/sezzle/sezzle-go-xml"
 )
 
 type Schemaer interface {
-	EncodeElement(name string, enc *xml.Encoder, sr SchemaRepository, params map[string]interface{}, useNamespace, keepUsingNamespace bool, path ...string) (err error)
-	EncodeType(name string, enc *xml.Encoder, sr SchemaRepository, params map[string]interface{}, useNamespace, keepUsingNamespace bool, path ...string) (err error)
+	EncodeElement(name string, enc *Encoder, sr SchemaRepository, params map[string]interface{}, path ...string) (err error)
+	EncodeType(name string, enc *Encoder, sr SchemaRepository, params map[string]interface{}, path ...string) (err error)
+	// EncodeTypeAttributes returns the attributes of the type name for the element at path, and whether it allows
+	// any other attribute
+	EncodeTypeAttributes(name string, enc *Encoder, sr SchemaRepository, params map[string]interface{}, path ...string) (attrs []xml.Attr, anyAttribute bool, err error)
+	// GetComplexType returns the named complex type and the schema it is defined in
+	GetComplexType(name string) (*ComplexType, GetAliaser, error)
+	// GetElement returns the global element name and the schema it is defined in
+	GetElement(name string) (*Element, GetAliaser, error)
+	// GetAttribute returns the global attribute name and the schema it is defined in
+	GetAttribute(name string) (*Attribute, GetAliaser, error)
+	// GetGroup returns the named model group and the schema it is defined in
+	GetGroup(name string) (*Group, GetAliaser, error)
+	EncodeAttributeGroup(name string, enc *Encoder, sr SchemaRepository, params map[string]interface{}, path ...string) (attrs []xml.Attr, anyAttribute bool, err error)
+	// GetSimpleType returns the named simple type and the schema it is defined in
+	GetSimpleType(name string) (*SimpleType, GetAliaser, error)
+	// FormatType returns the lexical representation of v for the simple type name
+	FormatType(name string, enc *Encoder, sr SchemaRepository, v interface{}, path ...string) (string, error)
 }
 
 type GetAliaser interface {
 	GetAlias(string) string
 	Namespace() string
+	// QualifiesElement reports whether a local element of the schema with the form attribute form is qualified
+	QualifiesElement(form string) bool
+	// QualifiesAttribute reports whether a local attribute of the schema with the form attribute form is qualified
+	QualifiesAttribute(form string) bool
 }
 
 type SchemaRepository interface {
 	GetSchema(space string) (Schemaer, error)
 }
 
+// hasPrefix reports whether a param has been submitted for the path prefix or anything below it
 func hasPrefix(m map[string]interface{}, prefix string) bool {
 	for k := range m {
-		ok := strings.HasPrefix(k, prefix)
+		ok := k == prefix || strings.HasPrefix(k, prefix+"/")
 		if ok {
 			return true
 		}
@@ -31,6 +53,44 @@ func hasPrefix(m map[string]interface{}, prefix string) bool {
 	return false
 }
 
+// defaultAlias is the alias of the default namespace, declared by xmlns="..."
+const defaultAlias = "xmlns"
+
+// splitQName splits the qualified name, like "tns:ItemType", into its prefix and its local name. Unprefixed names are
+// in the default namespace, so their prefix is defaultAlias.
+func splitQName(name string) []string {
+	if name != "" && !strings.Contains(name, ":") {
+		return []string{defaultAlias, name}
+	}
+
+	return strings.Split(name, ":")
+}
+
 func MakePath(path []string) string {
 	return strings.Join(path, "/")
 }
+
+// appendPath returns a copy of path with name appended, so paths handed down to nested elements never share memory
+func appendPath(path []string, name string) []string {
+	p := make([]string, len(path), len(path)+1)
+	copy(p, path)
+	return append(p, name)
+}
+
+const unbounded = -1
+
+// occurs parses minOccurs and maxOccurs, both default to 1 and a maxOccurs of "unbounded" is returned as unbounded
+func occurs(minOccurs, maxOccurs string) (min, max int) {
+	min, max = 1, 1
+	if n, err := strconv.Atoi(minOccurs); err == nil {
+		min = n
+	}
+
+	if maxOccurs == "unbounded" {
+		max = unbounded
+	} else if n, err := strconv.Atoi(maxOccurs); err == nil {
+		max = n
+	}
+
+	return min, max
+}
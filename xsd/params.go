@@ -0,0 +1,258 @@
+package xsd
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Params : Returns the params for the element name as flat map of slash paths. v is either such a map already, which
+// is copied, or a struct or a pointer to one, whose fields are mapped onto the children of the element by their
+// xml tag, or their name if there is none. Fields follow the rules of encoding/xml: "-" skips a field, "a>b" nests
+// it, attr maps it onto the attribute path "element/@name" and omitempty leaves out zero values. Slices of structs
+// are kept as []map[string]interface{}, one map per occurrence of the element. Structs implementing Typed submit
+// their XSIType as "element/@xsi:type", nil pointers without omitempty are submitted as Nil. An empty name maps the
+// fields of the struct onto top level elements.
+func Params(name string, v interface{}) (map[string]interface{}, error) {
+	if m, ok := v.(map[string]interface{}); ok {
+		params := map[string]interface{}{}
+		for k, val := range m {
+			params[k] = val
+		}
+
+		return params, nil
+	}
+
+	params := map[string]interface{}{}
+	if v == nil {
+		return params, nil
+	}
+
+	val := indirect(reflect.ValueOf(v))
+	if !val.IsValid() {
+		return params, nil
+	}
+
+	if val.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("params for '%s' must be a map[string]interface{} or a struct, got %s", name, val.Type())
+	}
+
+	// The fields of a struct for several elements, like the parts of a message, are the elements themselves
+	var path []string
+	if name != "" {
+		path = []string{name}
+		if t, ok := typed(val); ok {
+			params[MakePath(AttributePath(path, xsiType))] = t.XSIType()
+		}
+	}
+
+	err := flattenStruct(params, val, path)
+	return params, err
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+
+	return v
+}
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+func flattenStruct(params map[string]interface{}, v reflect.Value, path []string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" || f.Name == "XMLName" {
+			continue
+		}
+
+		tag := f.Tag.Get("xml")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts := tag, ""
+		if i := strings.Index(tag, ","); i >= 0 {
+			name, opts = tag[:i], tag[i+1:]
+		}
+		// A namespace in front of the name is given by the schema already, except for the xsi:type attribute
+		if i := strings.LastIndex(name, " "); i >= 0 {
+			if name[:i] == XSINamespace {
+				name = "xsi:" + name[i+1:]
+			} else {
+				name = name[i+1:]
+			}
+		}
+
+		fieldPath := path
+		switch {
+		case hasOption(opts, "chardata"):
+		case hasOption(opts, "attr"):
+			if name == "" {
+				name = f.Name
+			}
+			fieldPath = AttributePath(path, name)
+		case hasOption(opts, "innerxml"), hasOption(opts, "comment"), hasOption(opts, "any"):
+			return fmt.Errorf("field '%s' in path %q: xml tag option '%s' is not supported for params", f.Name, MakePath(path), opts)
+		case name == "" && f.Anonymous && indirect(v.Field(i)).Kind() == reflect.Struct:
+			// Embedded structs add their fields to the element, like encoding/xml does
+		case name == "":
+			fieldPath = appendPath(path, f.Name)
+		default:
+			for _, n := range strings.Split(name, ">") {
+				fieldPath = appendPath(fieldPath, n)
+			}
+		}
+
+		field := v.Field(i)
+		if hasOption(opts, "omitempty") && isEmptyValue(field) {
+			continue
+		}
+
+		err := flattenValue(params, field, fieldPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func flattenValue(params map[string]interface{}, v reflect.Value, path []string) error {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		// Attributes can't be nil, they are left out
+		if len(path) == 0 || !strings.HasPrefix(path[len(path)-1], "@") {
+			params[MakePath(path)] = Nil
+		}
+		return nil
+	}
+
+	if (isScalar(v.Type()) || v.Type().Implements(textMarshalerType)) && (v.Kind() != reflect.Ptr || !v.IsNil()) {
+		params[MakePath(path)] = v.Interface()
+		return nil
+	}
+
+	v = indirect(v)
+	if !v.IsValid() {
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		// A struct without fields stands for an element without content
+		if v.Type().Implements(textMarshalerType) || v.NumField() == 0 {
+			params[MakePath(path)] = v.Interface()
+			return nil
+		}
+
+		if t, ok := typed(v); ok {
+			params[MakePath(AttributePath(path, xsiType))] = t.XSIType()
+		}
+
+		return flattenStruct(params, v, path)
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("map in path %q must have string keys, got %s", MakePath(path), v.Type())
+		}
+
+		for _, k := range v.MapKeys() {
+			err := flattenValue(params, v.MapIndex(k), appendPath(path, k.String()))
+			if err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Len() == 0 || v.Type().Elem().Kind() == reflect.Uint8 {
+			if v.Len() > 0 {
+				params[MakePath(path)] = v.Interface()
+			}
+			return nil
+		}
+
+		if !isComplex(v.Type().Elem()) {
+			params[MakePath(path)] = v.Interface()
+			return nil
+		}
+
+		// Every item becomes its own occurrence of the element, with paths relative to it
+		items := make([]map[string]interface{}, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			items[i] = map[string]interface{}{}
+			err := flattenValue(items[i], v.Index(i), nil)
+			if err != nil {
+				return err
+			}
+		}
+		params[MakePath(path)] = items
+	default:
+		params[MakePath(path)] = v.Interface()
+	}
+
+	return nil
+}
+
+// typed returns v as Typed, if it or a pointer to it implements it
+func typed(v reflect.Value) (Typed, bool) {
+	if t, ok := v.Interface().(Typed); ok {
+		return t, true
+	}
+
+	if v.CanAddr() {
+		t, ok := v.Addr().Interface().(Typed)
+		return t, ok
+	}
+
+	return nil, false
+}
+
+// isComplex reports whether values of t are flattened into several params
+func isComplex(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		if isScalar(t) || t.Implements(textMarshalerType) {
+			return false
+		}
+		t = t.Elem()
+	}
+
+	if isScalar(t) || t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType) {
+		return false
+	}
+
+	return t.Kind() == reflect.Struct || t.Kind() == reflect.Map || t.Kind() == reflect.Interface
+}
+
+func hasOption(opts, option string) bool {
+	for _, o := range strings.Split(opts, ",") {
+		if o == option {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isEmptyValue reports whether v is the zero value of omitempty, as defined by encoding/xml
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+
+	return false
+}
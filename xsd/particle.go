@@ -0,0 +1,405 @@
+package xsd
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/sezzle/sezzle-go-xml"
+)
+
+// Kinds of the particles a content model is built of
+const (
+	ElementParticle  = "element"
+	SequenceParticle = "sequence"
+	ChoiceParticle   = "choice"
+	AllParticle      = "all"
+	GroupParticle    = "group"
+	AnyParticle      = "any"
+)
+
+// Particle is a node of the content model of a complex type or a model group. Sequences, choices and alls hold their
+// children in document order, so nested compositors are encoded in the order the schema declares them.
+type Particle struct {
+	Kind      string
+	MinOccurs string
+	MaxOccurs string
+
+	Element         *Element   // The element of an element particle
+	Ref             string     // The model group referenced by a group particle
+	Namespace       string     // The namespaces allowed by an any particle
+	ProcessContents string     // How an any particle is validated, ignored by the encoder
+	Particles       []Particle // The children of a sequence, choice or all
+}
+
+func isParticle(name string) bool {
+	switch name {
+	case ElementParticle, SequenceParticle, ChoiceParticle, AllParticle, GroupParticle, AnyParticle:
+		return true
+	}
+
+	return false
+}
+
+// decodeParticle decodes the particle start is the start element of
+func decodeParticle(d *xml.Decoder, start xml.StartElement) (Particle, error) {
+	p := Particle{
+		Kind:      start.Name.Local,
+		MinOccurs: attrValue(start, "minOccurs"),
+		MaxOccurs: attrValue(start, "maxOccurs"),
+	}
+
+	switch p.Kind {
+	case ElementParticle:
+		p.Element = new(Element)
+		return p, d.DecodeElement(p.Element, &start)
+	case GroupParticle:
+		p.Ref = attrValue(start, "ref")
+		return p, d.Skip()
+	case AnyParticle:
+		p.Namespace = attrValue(start, "namespace")
+		p.ProcessContents = attrValue(start, "processContents")
+		return p, d.Skip()
+	}
+
+	err := decodeChildren(d, func(child xml.StartElement) error {
+		if !isParticle(child.Name.Local) {
+			return d.Skip()
+		}
+
+		c, err := decodeParticle(d, child)
+		if err != nil {
+			return err
+		}
+
+		p.Particles = append(p.Particles, c)
+		return nil
+	})
+
+	return p, err
+}
+
+// elements returns the elements which are direct children of the particle's compositor
+func (p *Particle) elements() []Element {
+	var elements []Element
+	for _, c := range p.Particles {
+		if c.Kind == ElementParticle {
+			elements = append(elements, *c.Element)
+		}
+	}
+
+	return elements
+}
+
+// resolveGroup returns the model group referenced by ref, and the schema it is defined in
+func resolveGroup(ref string, sr SchemaRepository, ga GetAliaser) (*Group, GetAliaser, error) {
+	parts := splitQName(ref)
+	if len(parts) != 2 {
+		return nil, nil, fmt.Errorf("malformed group ref '%s'", ref)
+	}
+
+	schema, err := sr.GetSchema(ga.GetAlias(parts[0]))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return schema.GetGroup(parts[1])
+}
+
+// ElementNames : Returns the names of all elements the particle might contain, including those of referenced groups
+func (p *Particle) ElementNames(sr SchemaRepository, ga GetAliaser) []string {
+	switch p.Kind {
+	case ElementParticle:
+		return []string{p.Element.localName()}
+	case GroupParticle:
+		g, groupSchema, err := resolveGroup(p.Ref, sr, ga)
+		if err != nil || g.Particle == nil {
+			return nil
+		}
+
+		return g.Particle.ElementNames(sr, groupSchema)
+	}
+
+	var names []string
+	for i := range p.Particles {
+		names = append(names, p.Particles[i].ElementNames(sr, ga)...)
+	}
+
+	return names
+}
+
+// submitted reports whether a param has been submitted for any element of the particle
+func (p *Particle) submitted(sr SchemaRepository, ga GetAliaser, params map[string]interface{}, path ...string) bool {
+	if p.Kind == AnyParticle {
+		return len(anyParams(nil, params, path...)) > 0
+	}
+
+	for _, name := range p.ElementNames(sr, ga) {
+		if hasPrefix(params, MakePath(appendPath(path, name))) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// emptiable reports whether the particle may be left out without violating the schema
+func (p *Particle) emptiable(sr SchemaRepository, ga GetAliaser) bool {
+	if min, _ := occurs(p.MinOccurs, p.MaxOccurs); min == 0 {
+		return true
+	}
+
+	switch p.Kind {
+	case SequenceParticle, AllParticle:
+		for i := range p.Particles {
+			if !p.Particles[i].emptiable(sr, ga) {
+				return false
+			}
+		}
+		return true
+	case ChoiceParticle:
+		for i := range p.Particles {
+			if p.Particles[i].emptiable(sr, ga) {
+				return true
+			}
+		}
+		return false
+	case GroupParticle:
+		g, groupSchema, err := resolveGroup(p.Ref, sr, ga)
+		return err == nil && (g.Particle == nil || g.Particle.emptiable(sr, groupSchema))
+	}
+
+	return false
+}
+
+// Encode : Encodes every occurrence of the particle submitted on the params. Like elements, compositors and groups
+// with a minOccurs above zero are encoded even without params, so that missing required data gets reported.
+func (p *Particle) Encode(enc *Encoder, sr SchemaRepository, ga GetAliaser, params map[string]interface{}, path ...string) error {
+	switch p.Kind {
+	case ElementParticle:
+		// Elements take care of their occurrences themselves
+		return p.Element.Encode(enc, sr, ga, params, path...)
+	case AnyParticle:
+		return p.encodeAny(enc, params, path...)
+	}
+
+	content, contentSchema := p, ga
+	if p.Kind == GroupParticle {
+		g, groupSchema, err := resolveGroup(p.Ref, sr, ga)
+		if err != nil {
+			return err
+		}
+
+		if g.Particle == nil {
+			return nil
+		}
+		content, contentSchema = g.Particle, groupSchema
+	}
+
+	minOccurs, maxOccurs := occurs(p.MinOccurs, p.MaxOccurs)
+
+	var occurrences int
+	for maxOccurs == unbounded || occurrences < maxOccurs {
+		submitted := content.submitted(sr, contentSchema, params, path...)
+		if !submitted && (occurrences > 0 || minOccurs == 0) {
+			break
+		}
+
+		consumed := enc.consumed
+		err := content.encodeOccurrence(enc, sr, contentSchema, params, maxOccurs != 1, path...)
+		if err != nil {
+			return err
+		}
+		occurrences++
+
+		// An occurrence which did not consume any params would be repeated forever
+		if !submitted || enc.consumed == consumed {
+			break
+		}
+	}
+
+	if occurrences == 0 {
+		for _, name := range content.ElementNames(sr, contentSchema) {
+			enc.visit(appendPath(path, name))
+		}
+	}
+
+	return nil
+}
+
+func (p *Particle) encodeOccurrence(enc *Encoder, sr SchemaRepository, ga GetAliaser, params map[string]interface{}, repeatable bool, path ...string) error {
+	if repeatable {
+		enc.repeated++
+		defer func() {
+			enc.repeated--
+		}()
+	}
+
+	switch p.Kind {
+	case SequenceParticle:
+		for i := range p.Particles {
+			err := p.Particles[i].Encode(enc, sr, ga, params, path...)
+			if err != nil {
+				return err
+			}
+		}
+	case ChoiceParticle:
+		return p.encodeChoice(enc, sr, ga, params, path...)
+	case AllParticle:
+		// The order of an xs:all is free, so its elements are encoded in the order of the schema, but none of them
+		// may occur more than once
+		for i := range p.Particles {
+			c := &p.Particles[i]
+			if _, max := occurs(c.MinOccurs, c.MaxOccurs); c.Kind != ElementParticle || max > 1 || max == unbounded {
+				return fmt.Errorf("xs:all in path %q may only contain elements which occur at most once", path)
+			}
+
+			err := c.Encode(enc, sr, ga, params, path...)
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unknown particle '%s' in path %q", p.Kind, path)
+	}
+
+	return nil
+}
+
+// encodeChoice encodes the one branch of the choice which has been submitted. If the choice itself may occur more
+// than once, every occurrence encodes the next submitted branch.
+func (p *Particle) encodeChoice(enc *Encoder, sr SchemaRepository, ga GetAliaser, params map[string]interface{}, path ...string) error {
+	// First, verify that one and only one of the choices for this path has been submitted on the params
+	// If none, do not encode, unless the choice is required
+	// If more than one, report a validation error
+	// If one, start encoding - if any of the child element types are also choices, they will need to meet the same criteria
+	var submitted []*Particle
+	var submittedNames, names []string
+	var emptiable bool
+	for i := range p.Particles {
+		c := &p.Particles[i]
+		branchNames := c.ElementNames(sr, ga)
+		for _, name := range branchNames {
+			enc.visit(appendPath(path, name))
+		}
+		names = append(names, branchNames...)
+		emptiable = emptiable || c.emptiable(sr, ga)
+
+		if c.submitted(sr, ga, params, path...) {
+			submitted = append(submitted, c)
+			for _, name := range branchNames {
+				if hasPrefix(params, MakePath(appendPath(path, name))) {
+					submittedNames = append(submittedNames, name)
+					break
+				}
+			}
+		}
+	}
+
+	if _, max := occurs(p.MinOccurs, p.MaxOccurs); len(submitted) > 1 && max == 1 {
+		enc.Invalid(path, "A max of one choice element can be submitted, got %q", submittedNames)
+		return nil
+	}
+
+	if len(submitted) == 0 {
+		if !emptiable {
+			enc.Invalid(path, "one of the choice elements %q must be submitted", names)
+		}
+		return nil
+	}
+
+	return submitted[0].Encode(enc, sr, ga, params, path...)
+}
+
+// anyParams returns the names of the elements submitted below path which are not declared by the content model
+func anyParams(declared map[string]bool, params map[string]interface{}, path ...string) []string {
+	prefix := MakePath(path) + "/"
+	found := map[string]bool{}
+	for k := range params {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+
+		name := strings.SplitN(k[len(prefix):], "/", 2)[0]
+		if !strings.HasPrefix(name, "@") && !declared[name] {
+			found[name] = true
+		}
+	}
+
+	names := make([]string, 0, len(found))
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// encodeAny encodes every element submitted below path which is not declared by the content model of the enclosing
+// complex type as is, sorted by name. Values are written by their default format.
+func (p *Particle) encodeAny(enc *Encoder, params map[string]interface{}, path ...string) error {
+	names := anyParams(enc.declaredNames(), params, path...)
+	if min, _ := occurs(p.MinOccurs, p.MaxOccurs); len(names) == 0 && min > 0 {
+		enc.Invalid(path, "an element for xs:any must be submitted")
+	}
+
+	for _, name := range names {
+		err := encodeAnyElement(enc, params, appendPath(path, name))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// encodeAnyElement writes the element at path with all params below it, taking them from params
+func encodeAnyElement(enc *Encoder, params map[string]interface{}, path []string) error {
+	key := MakePath(path)
+	enc.expand(path)
+
+	// Every value of a slice is an occurrence of its own
+	if v, ok := params[key]; ok && reflect.ValueOf(v).Kind() == reflect.Slice && !isScalar(reflect.TypeOf(v)) && !hasPrefix(params, key+"/") {
+		for {
+			item, ok := takeParam(params, key)
+			if !ok {
+				return nil
+			}
+			enc.consumed++
+
+			start := xml.StartElement{Name: xml.Name{Local: path[len(path)-1]}}
+			err := enc.EncodeElement(formatAnyValue(item), start)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	start := xml.StartElement{
+		Name: xml.Name{Local: path[len(path)-1]},
+		Attr: encodeAnyAttributes(enc, params, path...),
+	}
+	err := enc.EncodeToken(start)
+	if err != nil {
+		return err
+	}
+
+	if v, ok := takeParam(params, key); ok {
+		enc.consumed++
+
+		err = enc.EncodeToken(xml.CharData(formatAnyValue(v)))
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, name := range anyParams(nil, params, path...) {
+		err = encodeAnyElement(enc, params, appendPath(path, name))
+		if err != nil {
+			return err
+		}
+	}
+
+	return enc.EncodeToken(start.End())
+}
@@ -7,18 +7,38 @@ import (
 )
 
 type InnerSchema struct {
-	TargetNamespace    string        `xml:"targetNamespace,attr"`
-	ElementFormDefault string        `xml:"elementFormDefault,attr"`
-	Version            string        `xml:"version,attr"`
-	ComplexTypes       []ComplexType `xml:"http://www.w3.org/2001/XMLSchema complexType"`
-	SimpleTypes        []SimpleType  `xml:"http://www.w3.org/2001/XMLSchema simpleType"`
-	Elements           []Element     `xml:"http://www.w3.org/2001/XMLSchema element"`
+	TargetNamespace      string           `xml:"targetNamespace,attr"`
+	ElementFormDefault   string           `xml:"elementFormDefault,attr"`
+	AttributeFormDefault string           `xml:"attributeFormDefault,attr"`
+	Version              string           `xml:"version,attr"`
+	ComplexTypes         []ComplexType    `xml:"http://www.w3.org/2001/XMLSchema complexType"`
+	SimpleTypes          []SimpleType     `xml:"http://www.w3.org/2001/XMLSchema simpleType"`
+	Elements             []Element        `xml:"http://www.w3.org/2001/XMLSchema element"`
+	Attributes           []Attribute      `xml:"http://www.w3.org/2001/XMLSchema attribute"`
+	AttributeGroups      []AttributeGroup `xml:"http://www.w3.org/2001/XMLSchema attributeGroup"`
+	Groups               []Group          `xml:"http://www.w3.org/2001/XMLSchema group"`
+	Imports              []SchemaImport   `xml:"http://www.w3.org/2001/XMLSchema import"`
+	Includes             []SchemaInclude  `xml:"http://www.w3.org/2001/XMLSchema include"`
 }
 
 type Schema struct {
 	XMLName xml.Name `xml:"http://www.w3.org/2001/XMLSchema schema"`
 	Aliases map[string]string
 	InnerSchema
+
+	// Included holds the schemas added by Include, which are part of the namespace of the schema
+	Included []Schema `xml:"-"`
+}
+
+// SchemaImport imports the components of another namespace, which are loaded from SchemaLocation if it is given
+type SchemaImport struct {
+	Namespace      string `xml:"namespace,attr"`
+	SchemaLocation string `xml:"schemaLocation,attr"`
+}
+
+// SchemaInclude includes the components of the schema at SchemaLocation into the namespace of the including schema
+type SchemaInclude struct {
+	SchemaLocation string `xml:"schemaLocation,attr"`
 }
 
 func (s *Schema) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
@@ -40,35 +60,191 @@ func (s *Schema) Namespace() string {
 	return s.TargetNamespace
 }
 
+func (s *Schema) QualifiesElement(form string) bool {
+	if form == "" {
+		form = s.ElementFormDefault
+	}
+
+	return form == "qualified"
+}
+
+func (s *Schema) QualifiesAttribute(form string) bool {
+	if form == "" {
+		form = s.AttributeFormDefault
+	}
+
+	return form == "qualified"
+}
+
 func (s *Schema) GetAlias(alias string) (space string) {
 	return s.Aliases[alias]
 }
 
 // EncodeElement : Begins encoding to XML from the top level body element, calling Encode and EncodeType recursively on the
 // nested elements until there are no more to be encoded.
-func (s *Schema) EncodeElement(name string, enc *xml.Encoder, sr SchemaRepository, params map[string]interface{}, useNamespace, keepUsingNamespace bool, path ...string) error {
+func (s *Schema) EncodeElement(name string, enc *Encoder, sr SchemaRepository, params map[string]interface{}, path ...string) error {
 	// Starts encoding the top level xml element
-	for _, elem := range s.Elements {
-		if elem.Name == name {
-			return elem.Encode(enc, sr, s, params, useNamespace, keepUsingNamespace, path...)
+	for _, schema := range s.schemas() {
+		for _, elem := range schema.Elements {
+			if elem.Name == name {
+				elem.global = true
+				return elem.Encode(enc, sr, schema, params, path...)
+			}
 		}
 	}
 
 	return fmt.Errorf("did not find element '%s'", name)
 }
 
-func (s *Schema) EncodeType(name string, enc *xml.Encoder, sr SchemaRepository, params map[string]interface{}, useNamespace, keepUsingNamespace bool, path ...string) error {
-	for _, cmplx := range s.ComplexTypes {
-		if cmplx.Name == name {
-			return cmplx.Encode(enc, sr, s, params, useNamespace, keepUsingNamespace, path...)
+func (s *Schema) EncodeType(name string, enc *Encoder, sr SchemaRepository, params map[string]interface{}, path ...string) error {
+	for _, schema := range s.schemas() {
+		for _, cmplx := range schema.ComplexTypes {
+			if cmplx.Name == name {
+				return cmplx.Encode(enc, sr, schema, params, path...)
+			}
 		}
-	}
 
-	for _, smpl := range s.SimpleTypes {
-		if smpl.Name == name {
-			return smpl.Encode(enc, sr, s, params, useNamespace, keepUsingNamespace, path...)
+		for _, smpl := range schema.SimpleTypes {
+			if smpl.Name == name {
+				return smpl.Encode(enc, sr, schema, params, path...)
+			}
 		}
 	}
 
 	return fmt.Errorf("did not find type '%s'", name)
 }
+
+func (s *Schema) EncodeTypeAttributes(name string, enc *Encoder, sr SchemaRepository, params map[string]interface{}, path ...string) ([]xml.Attr, bool, error) {
+	for _, schema := range s.schemas() {
+		for _, cmplx := range schema.ComplexTypes {
+			if cmplx.Name == name {
+				return cmplx.EncodeAttributes(enc, sr, schema, params, path...)
+			}
+		}
+
+		for _, smpl := range schema.SimpleTypes {
+			if smpl.Name == name {
+				return nil, false, nil
+			}
+		}
+	}
+
+	return nil, false, fmt.Errorf("did not find type '%s'", name)
+}
+
+func (s *Schema) GetComplexType(name string) (*ComplexType, GetAliaser, error) {
+	for _, schema := range s.schemas() {
+		for i := range schema.ComplexTypes {
+			if schema.ComplexTypes[i].Name == name {
+				return &schema.ComplexTypes[i], schema, nil
+			}
+		}
+	}
+
+	return nil, nil, fmt.Errorf("did not find complex type '%s'", name)
+}
+
+func (s *Schema) GetElement(name string) (*Element, GetAliaser, error) {
+	for _, schema := range s.schemas() {
+		for i := range schema.Elements {
+			if schema.Elements[i].Name == name {
+				return &schema.Elements[i], schema, nil
+			}
+		}
+	}
+
+	return nil, nil, fmt.Errorf("did not find element '%s'", name)
+}
+
+func (s *Schema) GetAttribute(name string) (*Attribute, GetAliaser, error) {
+	for _, schema := range s.schemas() {
+		for i := range schema.Attributes {
+			if schema.Attributes[i].Name == name {
+				return &schema.Attributes[i], schema, nil
+			}
+		}
+	}
+
+	return nil, nil, fmt.Errorf("did not find attribute '%s'", name)
+}
+
+func (s *Schema) GetGroup(name string) (*Group, GetAliaser, error) {
+	for _, schema := range s.schemas() {
+		for i := range schema.Groups {
+			if schema.Groups[i].Name == name {
+				return &schema.Groups[i], schema, nil
+			}
+		}
+	}
+
+	return nil, nil, fmt.Errorf("did not find group '%s'", name)
+}
+
+func (s *Schema) EncodeAttributeGroup(name string, enc *Encoder, sr SchemaRepository, params map[string]interface{}, path ...string) ([]xml.Attr, bool, error) {
+	for _, schema := range s.schemas() {
+		for _, g := range schema.AttributeGroups {
+			if g.Name == name {
+				return g.Encode(enc, sr, schema, params, path...)
+			}
+		}
+	}
+
+	return nil, false, fmt.Errorf("did not find attribute group '%s'", name)
+}
+
+func (s *Schema) GetSimpleType(name string) (*SimpleType, GetAliaser, error) {
+	for _, schema := range s.schemas() {
+		for i := range schema.SimpleTypes {
+			if schema.SimpleTypes[i].Name == name {
+				return &schema.SimpleTypes[i], schema, nil
+			}
+		}
+	}
+
+	return nil, nil, fmt.Errorf("did not find simple type '%s'", name)
+}
+
+func (s *Schema) FormatType(name string, enc *Encoder, sr SchemaRepository, v interface{}, path ...string) (string, error) {
+	for _, schema := range s.schemas() {
+		for _, smpl := range schema.SimpleTypes {
+			if smpl.Name == name {
+				return smpl.Format(enc, sr, schema, v, path...)
+			}
+		}
+	}
+
+	return "", fmt.Errorf("did not find simple type '%s'", name)
+}
+
+// Include : Adds the components of included, a schema included or imported for the same namespace, to the schema.
+// They keep the aliases of the document declaring them. A chameleon include adopts the namespace of the schema.
+func (s *Schema) Include(included Schema) {
+	included.Adopt(s.TargetNamespace)
+	s.Included = append(s.Included, included)
+}
+
+// Adopt : Puts a chameleon include, a schema without target namespace, into namespace. Its unprefixed references
+// are in namespace as well, unless it declares a default namespace.
+func (s *Schema) Adopt(namespace string) {
+	if s.TargetNamespace != "" {
+		return
+	}
+
+	s.TargetNamespace = namespace
+	if s.Aliases == nil {
+		s.Aliases = map[string]string{}
+	}
+	if s.Aliases[defaultAlias] == "" {
+		s.Aliases[defaultAlias] = namespace
+	}
+}
+
+// schemas returns the schema followed by every schema it includes, recursively
+func (s *Schema) schemas() []*Schema {
+	schemas := []*Schema{s}
+	for i := range s.Included {
+		schemas = append(schemas, s.Included[i].schemas()...)
+	}
+
+	return schemas
+}
@@ -0,0 +1,133 @@
+package xsd
+
+import (
+	"fmt"
+
+	"github.com/sezzle/sezzle-go-xml"
+)
+
+// Encode : Encodes the value submitted at the path of the element as its text. The attributes are encoded with the
+// start of the element by EncodeAttributes of the complex type.
+func (s *SimpleContent) Encode(enc *Encoder, sr SchemaRepository, ga GetAliaser, params map[string]interface{}, path ...string) error {
+	take := takeParam
+	if s.isList(sr, ga) {
+		take = takeList
+	}
+
+	v, ok := take(params, MakePath(path))
+	if !ok {
+		enc.Invalid(path, "did not find data '%s' in path", MakePath(path))
+		return nil
+	}
+	enc.consumed++
+
+	value, err := s.Format(enc, sr, ga, v, path...)
+	if enc.invalidValue(path, err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return enc.EncodeToken(xml.CharData(value))
+}
+
+// Format : Returns the lexical representation of v by the base of the derivation. The value of a restriction is
+// checked against its facets afterwards.
+func (s *SimpleContent) Format(enc *Encoder, sr SchemaRepository, ga GetAliaser, v interface{}, path ...string) (string, error) {
+	if s.Restriction == nil {
+		if s.Extension == nil {
+			return "", fmt.Errorf("simple content without extension or restriction in path %q", path)
+		}
+		return formatContent(enc, sr, ga, s.Extension.Base, v, path...)
+	}
+
+	r := s.Restriction
+	var value string
+	var err error
+	if r.Facets.SimpleType != nil {
+		value, err = r.Facets.SimpleType.Format(enc, sr, ga, v, path...)
+	} else {
+		value, err = formatContent(enc, sr, ga, r.Base, v, path...)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	builtin, err := s.builtin(sr, ga)
+	if err != nil {
+		return "", err
+	}
+
+	return r.Facets.check(builtin, value)
+}
+
+// builtin returns the name of the xsd built-in type the value of the simple content is derived from
+func (s *SimpleContent) builtin(sr SchemaRepository, ga GetAliaser) (string, error) {
+	base := ""
+	switch {
+	case s.Restriction != nil && s.Restriction.Facets.SimpleType != nil:
+		return s.Restriction.Facets.SimpleType.builtin(sr, ga)
+	case s.Restriction != nil:
+		base = s.Restriction.Base
+	case s.Extension != nil:
+		base = s.Extension.Base
+	}
+
+	parts := splitQName(base)
+	if len(parts) != 2 {
+		return "", fmt.Errorf("malformed base '%s'", base)
+	}
+
+	if ga.GetAlias(parts[0]) == schemaNamespace {
+		return parts[1], nil
+	}
+
+	schema, err := sr.GetSchema(ga.GetAlias(parts[0]))
+	if err != nil {
+		return "", err
+	}
+
+	if c, typeSchema, err := schema.GetComplexType(parts[1]); err == nil {
+		if c.SimpleContent == nil {
+			return "", fmt.Errorf("base '%s' of simple content has no simple content", base)
+		}
+		return c.SimpleContent.builtin(sr, typeSchema)
+	}
+
+	st, typeSchema, err := schema.GetSimpleType(parts[1])
+	if err != nil {
+		return "", err
+	}
+
+	return st.builtin(sr, typeSchema)
+}
+
+// isList reports whether the values of the simple content are lists
+func (s *SimpleContent) isList(sr SchemaRepository, ga GetAliaser) bool {
+	builtin, err := s.builtin(sr, ga)
+	return err == nil && (builtin == listBuiltin || builtinListItems[builtin] != "")
+}
+
+// formatContent returns the lexical representation of v for typeName, a qualified name of either a simple type or a
+// complex type with simple content
+func formatContent(enc *Encoder, sr SchemaRepository, ga GetAliaser, typeName string, v interface{}, path ...string) (string, error) {
+	parts := splitQName(typeName)
+	if len(parts) != 2 {
+		return "", fmt.Errorf("malformed base '%s' in path %q", typeName, path)
+	}
+
+	schema, err := sr.GetSchema(ga.GetAlias(parts[0]))
+	if err != nil {
+		return "", err
+	}
+
+	if c, typeSchema, err := schema.GetComplexType(parts[1]); err == nil {
+		if c.SimpleContent == nil {
+			return "", fmt.Errorf("base '%s' in path %q has no simple content", typeName, path)
+		}
+		return c.SimpleContent.Format(enc, sr, typeSchema, v, path...)
+	}
+
+	return schema.FormatType(parts[1], enc, sr, v, path...)
+}
@@ -2,21 +2,39 @@ package xsd
 
 import (
 	"fmt"
+	"reflect"
 	"strings"
 
 	"github.com/sezzle/sezzle-go-xml"
 )
 
+// SimpleType is defined by either a restriction, a list or a union
 type SimpleType struct {
 	XMLName     xml.Name              `xml:"http://www.w3.org/2001/XMLSchema simpleType"`
 	Name        string                `xml:"name,attr"`
 	Restriction SimpleTypeRestriction `xml:"restriction"`
+	List        *SimpleTypeList       `xml:"http://www.w3.org/2001/XMLSchema list"`
+	Union       *SimpleTypeUnion      `xml:"http://www.w3.org/2001/XMLSchema union"`
 }
 
+// SimpleTypeRestriction restricts the value space of its base by facets. The base is either referenced by Base, or
+// an anonymous SimpleType.
 type SimpleTypeRestriction struct {
-	XMLName      xml.Name      `xml:"http://www.w3.org/2001/XMLSchema restriction"`
-	Base         string        `xml:"base,attr"`
-	Enumerations []Enumeration `xml:"http://www.w3.org/2001/XMLSchema enumeration"`
+	XMLName        xml.Name      `xml:"http://www.w3.org/2001/XMLSchema restriction"`
+	Base           string        `xml:"base,attr"`
+	SimpleType     *SimpleType   `xml:"http://www.w3.org/2001/XMLSchema simpleType"`
+	Enumerations   []Enumeration `xml:"http://www.w3.org/2001/XMLSchema enumeration"`
+	Patterns       []Facet       `xml:"http://www.w3.org/2001/XMLSchema pattern"`
+	Length         *Facet        `xml:"http://www.w3.org/2001/XMLSchema length"`
+	MinLength      *Facet        `xml:"http://www.w3.org/2001/XMLSchema minLength"`
+	MaxLength      *Facet        `xml:"http://www.w3.org/2001/XMLSchema maxLength"`
+	MinInclusive   *Facet        `xml:"http://www.w3.org/2001/XMLSchema minInclusive"`
+	MaxInclusive   *Facet        `xml:"http://www.w3.org/2001/XMLSchema maxInclusive"`
+	MinExclusive   *Facet        `xml:"http://www.w3.org/2001/XMLSchema minExclusive"`
+	MaxExclusive   *Facet        `xml:"http://www.w3.org/2001/XMLSchema maxExclusive"`
+	TotalDigits    *Facet        `xml:"http://www.w3.org/2001/XMLSchema totalDigits"`
+	FractionDigits *Facet        `xml:"http://www.w3.org/2001/XMLSchema fractionDigits"`
+	WhiteSpace     *Facet        `xml:"http://www.w3.org/2001/XMLSchema whiteSpace"`
 }
 
 type Enumeration struct {
@@ -24,23 +42,246 @@ type Enumeration struct {
 	Value   string   `xml:"value,attr"`
 }
 
-func (s *SimpleType) Encode(enc *xml.Encoder, sr SchemaRepository, ga GetAliaser, params map[string]interface{}, useNamespace, keepUsingNamespace bool, path ...string) error {
+// SimpleTypeList has whitespace separated lists of values of the item type as values. The item type is either
+// referenced by ItemType, or an anonymous SimpleType.
+type SimpleTypeList struct {
+	XMLName    xml.Name    `xml:"http://www.w3.org/2001/XMLSchema list"`
+	ItemType   string      `xml:"itemType,attr"`
+	SimpleType *SimpleType `xml:"http://www.w3.org/2001/XMLSchema simpleType"`
+}
+
+// SimpleTypeUnion has the values of all its member types as values, the ones referenced by MemberTypes first
+type SimpleTypeUnion struct {
+	XMLName     xml.Name     `xml:"http://www.w3.org/2001/XMLSchema union"`
+	MemberTypes string       `xml:"memberTypes,attr"`
+	SimpleTypes []SimpleType `xml:"http://www.w3.org/2001/XMLSchema simpleType"`
+}
+
+// listBuiltin stands for the built-in type of list types, whose length is the number of their items
+const listBuiltin = "list"
+
+// Facet is a constraining facet of a restriction, like <maxLength value="10"/>
+type Facet struct {
+	Value string `xml:"value,attr"`
+}
+
+func (s *SimpleType) Encode(enc *Encoder, sr SchemaRepository, ga GetAliaser, params map[string]interface{}, path ...string) error {
+	take := takeParam
+	if s.isList(sr, ga) {
+		take = takeList
+	}
+
+	v, ok := take(params, MakePath(path))
+	if !ok {
+		enc.Invalid(path, "did not find data '%s' in path", MakePath(path))
+		return nil
+	}
+	enc.consumed++
+
+	value, err := s.Format(enc, sr, ga, v, path...)
+	if enc.invalidValue(path, err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return enc.EncodeToken(xml.CharData(value))
+}
+
+// Format : Returns the lexical representation of v by the base of the restriction, checked against the facets of
+// the restriction. Values of list types are slices, or a single item, values of union types are formatted by the
+// first member type they are valid for.
+func (s *SimpleType) Format(enc *Encoder, sr SchemaRepository, ga GetAliaser, v interface{}, path ...string) (string, error) {
+	switch {
+	case s.List != nil:
+		return s.List.Format(enc, sr, ga, v, path...)
+	case s.Union != nil:
+		return s.Union.Format(enc, sr, ga, v, path...)
+	}
+
+	value, err := s.formatBase(enc, sr, ga, v, path...)
+	if err != nil {
+		return "", err
+	}
+
+	builtin, err := s.builtin(sr, ga)
+	if err != nil {
+		return "", err
+	}
+
+	return s.Restriction.check(builtin, value)
+}
+
+func (s *SimpleType) formatBase(enc *Encoder, sr SchemaRepository, ga GetAliaser, v interface{}, path ...string) (string, error) {
+	if s.Restriction.SimpleType != nil {
+		return s.Restriction.SimpleType.Format(enc, sr, ga, v, path...)
+	}
+
 	name := s.Restriction.Base
-	parts := strings.Split(name, ":")
+	parts := splitQName(name)
 	switch len(parts) {
 	case 2:
 		schema, err := sr.GetSchema(ga.GetAlias(parts[0]))
 		if err != nil {
-			return err
+			return "", err
 		}
 
-		err = schema.EncodeType(parts[1], enc, sr, params, keepUsingNamespace, keepUsingNamespace, path...)
-		if err != nil {
-			return err
-		}
-		return nil
+		return schema.FormatType(parts[1], enc, sr, v, path...)
 	default:
 		err := fmt.Errorf("invalid restriction format '%s'", name)
-		return err
+		return "", err
+	}
+}
+
+// builtin returns the name of the xsd built-in type the simple type is derived from, like "string"
+func (s *SimpleType) builtin(sr SchemaRepository, ga GetAliaser) (string, error) {
+	switch {
+	case s.List != nil:
+		return listBuiltin, nil
+	case s.Union != nil:
+		return "anySimpleType", nil
+	case s.Restriction.SimpleType != nil:
+		return s.Restriction.SimpleType.builtin(sr, ga)
+	}
+
+	name := s.Restriction.Base
+	parts := splitQName(name)
+	if len(parts) != 2 {
+		return "", fmt.Errorf("invalid restriction format '%s'", name)
+	}
+
+	if ga.GetAlias(parts[0]) == schemaNamespace {
+		return parts[1], nil
+	}
+
+	schema, err := sr.GetSchema(ga.GetAlias(parts[0]))
+	if err != nil {
+		return "", err
+	}
+
+	base, baseSchema, err := schema.GetSimpleType(parts[1])
+	if err != nil {
+		return "", err
 	}
+
+	return base.builtin(sr, baseSchema)
+}
+
+// isList reports whether the values of the simple type are lists
+func (s *SimpleType) isList(sr SchemaRepository, ga GetAliaser) bool {
+	builtin, err := s.builtin(sr, ga)
+	return err == nil && (builtin == listBuiltin || builtinListItems[builtin] != "")
+}
+
+// Format : Returns the items of v, formatted by the item type and separated by spaces. A string is split into its
+// items first, any other value which is no slice is a list of a single item.
+func (l *SimpleTypeList) Format(enc *Encoder, sr SchemaRepository, ga GetAliaser, v interface{}, path ...string) (string, error) {
+	items := []interface{}{v}
+	if str, ok := v.(string); ok {
+		items = nil
+		for _, item := range strings.Fields(str) {
+			items = append(items, item)
+		}
+	} else if isListValue(v) {
+		val := reflect.ValueOf(v)
+		items = make([]interface{}, val.Len())
+		for i := range items {
+			items[i] = val.Index(i).Interface()
+		}
+	}
+
+	values := make([]string, len(items))
+	for i, item := range items {
+		var err error
+		if l.SimpleType != nil {
+			values[i], err = l.SimpleType.Format(enc, sr, ga, item, path...)
+		} else {
+			values[i], err = formatType(enc, sr, ga, l.ItemType, item, path...)
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return strings.Join(values, " "), nil
+}
+
+// Format : Returns the lexical representation of v by the first member type v is valid for
+func (u *SimpleTypeUnion) Format(enc *Encoder, sr SchemaRepository, ga GetAliaser, v interface{}, path ...string) (string, error) {
+	var members []string
+	for _, member := range strings.Fields(u.MemberTypes) {
+		value, err := formatType(enc, sr, ga, member, v, path...)
+		if _, ok := err.(invalidValueError); !ok {
+			return value, err
+		}
+		members = append(members, member)
+	}
+
+	for i := range u.SimpleTypes {
+		value, err := u.SimpleTypes[i].Format(enc, sr, ga, v, path...)
+		if _, ok := err.(invalidValueError); !ok {
+			return value, err
+		}
+		members = append(members, "anonymous simpleType")
+	}
+
+	return "", invalidValue("value %q is not valid for any member type of the union %q", fmt.Sprint(v), members)
+}
+
+// formatType returns the lexical representation of v for the simple type typeName, a qualified name
+func formatType(enc *Encoder, sr SchemaRepository, ga GetAliaser, typeName string, v interface{}, path ...string) (string, error) {
+	parts := splitQName(typeName)
+	if len(parts) != 2 {
+		return "", fmt.Errorf("malformed type '%s' in path %q", typeName, path)
+	}
+
+	schema, err := sr.GetSchema(ga.GetAlias(parts[0]))
+	if err != nil {
+		return "", err
+	}
+
+	return schema.FormatType(parts[1], enc, sr, v, path...)
+}
+
+// isListType reports whether the values of the simple type typeName, a qualified name, are lists
+func isListType(sr SchemaRepository, ga GetAliaser, typeName string) bool {
+	parts := splitQName(typeName)
+	if len(parts) != 2 {
+		return false
+	}
+
+	if ga.GetAlias(parts[0]) == schemaNamespace {
+		return builtinListItems[parts[1]] != ""
+	}
+
+	schema, err := sr.GetSchema(ga.GetAlias(parts[0]))
+	if err != nil {
+		return false
+	}
+
+	s, typeSchema, err := schema.GetSimpleType(parts[1])
+	return err == nil && s.isList(sr, typeSchema)
+}
+
+// isListValue reports whether v holds the items of a list
+func isListValue(v interface{}) bool {
+	val := reflect.ValueOf(v)
+	return (val.Kind() == reflect.Slice || val.Kind() == reflect.Array) && !isScalar(val.Type())
+}
+
+// takeList removes the value of a list type at key from params. A slice is the list of a single occurrence, unless
+// its items are lists themselves, then only the first one is taken.
+func takeList(params map[string]interface{}, key string) (interface{}, bool) {
+	v, ok := params[key]
+	if !ok {
+		return nil, false
+	}
+
+	if val := reflect.ValueOf(v); isListValue(v) && val.Len() > 0 && isListValue(val.Index(0).Interface()) {
+		return takeParam(params, key)
+	}
+
+	delete(params, key)
+	return v, true
 }
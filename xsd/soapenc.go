@@ -0,0 +1,148 @@
+package xsd
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/sezzle/sezzle-go-xml"
+)
+
+// SOAPEncodingNamespace is the namespace of the SOAP encoding of section 5 of SOAP 1.1, which is used by operations
+// with use="encoded"
+const SOAPEncodingNamespace = "http://schemas.xmlsoap.org/soap/encoding/"
+
+// arrayItem is the name of the elements holding the items of a soapenc:Array
+const arrayItem = "item"
+
+// SetEncoding : Switches the encoder to SOAP encoding. Every value states its type by xsi:type, arrays derived from
+// soapenc:Array state their item type and length by soapenc:arrayType, and encodingStyle, the attribute naming the
+// encoding, is added to the outermost element.
+func (enc *Encoder) SetEncoding(encodingStyle xml.Attr) {
+	enc.encodingStyle = &encodingStyle
+}
+
+// encodingAttrs returns the attributes of the outermost element of a SOAP encoded message: the namespaces used by the
+// xsi:type attributes below it and the encodingStyle. Nested elements get none. The returned func ends the scope of
+// the declarations, it has to be called when the element ends.
+func (enc *Encoder) encodingAttrs() ([]xml.Attr, func()) {
+	if enc.encodingStyle == nil || enc.inEncoding {
+		return nil, func() {}
+	}
+
+	enc.inEncoding = true
+	var attrs []xml.Attr
+	var ends []func()
+	for _, namespace := range []string{XSINamespace, schemaNamespace, SOAPEncodingNamespace} {
+		_, decl, end := enc.declareNamespace(namespace)
+		attrs = append(attrs, decl...)
+		ends = append(ends, end)
+	}
+
+	return append(attrs, *enc.encodingStyle), func() {
+		for _, end := range ends {
+			end()
+		}
+		enc.inEncoding = false
+	}
+}
+
+// arrayType returns the item type of the complex type name of schema, if it is an array derived from soapenc:Array.
+// The item type is declared by the wsdl:arrayType of its soapenc:arrayType attribute.
+func arrayType(schema Schemaer, name string) (item qName, ok bool, err error) {
+	c, ga, err := schema.GetComplexType(name)
+	if err != nil || c.Content == nil || c.Content.Restriction == nil {
+		return qName{}, false, nil
+	}
+
+	r := c.Content.Restriction
+	base, err := resolveQName(r.Base, ga)
+	if err != nil || base != (qName{space: SOAPEncodingNamespace, local: "Array"}) {
+		return qName{}, false, err
+	}
+
+	for _, a := range r.Attributes {
+		if a.ArrayType == "" {
+			continue
+		}
+
+		// The dimensions of the array, like [] or [,], follow the item type
+		t := a.ArrayType
+		if i := strings.Index(t, "["); i >= 0 {
+			t = t[:i]
+		}
+
+		item, err = resolveQName(t, ga)
+		return item, true, err
+	}
+
+	return qName{}, false, fmt.Errorf("array type '%s' does not declare its item type by wsdl:arrayType", name)
+}
+
+// arrayAttrs returns the xsi:type and soapenc:arrayType attributes of an array of n items of the type item, preceded
+// by the namespace declarations they need. The returned func ends the scope of the declarations.
+func arrayAttrs(enc *Encoder, item qName, n int) ([]xml.Attr, func()) {
+	attrs, endType := xsiTypeAttrs(enc, qName{space: SOAPEncodingNamespace, local: "Array"})
+	encPrefix, encDecl, endEnc := enc.declareNamespace(SOAPEncodingNamespace)
+	itemPrefix, itemDecl, endItem := enc.declareNamespace(item.space)
+
+	attrs = append(append(encDecl, itemDecl...), attrs...)
+	attrs = append(attrs, xml.Attr{
+		Name:  xml.Name{Prefix: encPrefix, Local: "arrayType"},
+		Value: fmt.Sprintf("%s:%s[%d]", itemPrefix, item.local, n),
+	})
+	return attrs, func() {
+		endItem()
+		endEnc()
+		endType()
+	}
+}
+
+// arrayLength returns the number of items submitted for the array at path, the most values of any param below its
+// items
+func arrayLength(params map[string]interface{}, path ...string) int {
+	prefix := MakePath(appendPath(path, arrayItem))
+	var n int
+	for k, v := range params {
+		if k != prefix && !strings.HasPrefix(k, prefix+"/") {
+			continue
+		}
+
+		l := 1
+		if val := reflect.ValueOf(v); val.Kind() == reflect.Slice && !isScalar(val.Type()) {
+			l = val.Len()
+		}
+		if l > n {
+			n = l
+		}
+	}
+
+	return n
+}
+
+// encodeArray encodes the items of the array at path, which are submitted at "array/item"
+func encodeArray(enc *Encoder, sr SchemaRepository, item qName, params map[string]interface{}, path ...string) error {
+	e := Element{Name: arrayItem, Type: "item:" + item.local, MinOccurs: "0", MaxOccurs: "unbounded"}
+	return e.Encode(enc, sr, itemAliaser{item.space}, params, path...)
+}
+
+// itemAliaser resolves the type of the items of an array, which are unqualified
+type itemAliaser struct {
+	space string
+}
+
+func (a itemAliaser) GetAlias(string) string {
+	return a.space
+}
+
+func (a itemAliaser) Namespace() string {
+	return ""
+}
+
+func (a itemAliaser) QualifiesElement(string) bool {
+	return false
+}
+
+func (a itemAliaser) QualifiesAttribute(string) bool {
+	return false
+}
@@ -0,0 +1,212 @@
+package xsd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// UnknownParam is a param which was left over after encoding a request
+type UnknownParam struct {
+	Path string
+	// Known is set if the path matches a schema element, but its value was not consumed, like a value submitted for a
+	// complex element
+	Known bool
+	// Suggestion is the closest valid path, if there is one close enough to be a likely typo
+	Suggestion string
+	// Empty is set for an attribute submitted with an empty value, which is left out since it can't be written
+	Empty bool
+}
+
+func (p UnknownParam) Error() string {
+	return fmt.Sprintf("%s: %s", p.Path, p.message())
+}
+
+func (p UnknownParam) message() string {
+	switch {
+	case p.Empty:
+		return "attribute with an empty value can't be sent, it was left out"
+	case p.Known:
+		return "value was not consumed by the schema"
+	case p.Suggestion != "":
+		return fmt.Sprintf("unknown param, did you mean '%s'?", p.Suggestion)
+	default:
+		return "unknown param"
+	}
+}
+
+// UnknownParamsError holds every param which matched no schema element or was not consumed, sorted by path. The
+// request itself has been written completely when WriteRequest returns it.
+type UnknownParamsError []UnknownParam
+
+func (e UnknownParamsError) Error() string {
+	msgs := make([]string, len(e))
+	for i := range e {
+		msgs[i] = e[i].Error()
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// visit records path as a valid param path, so it can be suggested for unknown params
+func (enc *Encoder) visit(path []string) {
+	if enc.known == nil {
+		enc.known = map[string]bool{}
+	}
+
+	p := MakePath(path)
+	if _, ok := enc.known[p]; !ok {
+		enc.known[p] = false
+	}
+}
+
+// expand records that the content of the element at path has been encoded, so params below it which are left over
+// are unknown
+func (enc *Encoder) expand(path []string) {
+	enc.visit(path)
+	enc.known[MakePath(path)] = true
+}
+
+// Unknown : Records every param left in params after encoding as unknown param. Call it with the params of each
+// element encoded, once all of them are done. Params below an element which was left out because of a validation
+// error can't be told apart from valid ones, those are skipped.
+func (enc *Encoder) Unknown(params map[string]interface{}) {
+	paths := make([]string, 0, len(params))
+	for k := range params {
+		paths = append(paths, k)
+	}
+	sort.Strings(paths)
+
+	for _, p := range paths {
+		if _, ok := enc.known[p]; ok {
+			enc.unknown = append(enc.unknown, UnknownParam{Path: p, Known: true})
+			continue
+		}
+
+		if !enc.expandedParent(p) {
+			continue
+		}
+
+		enc.unknown = append(enc.unknown, UnknownParam{Path: p, Suggestion: enc.closestPath(p)})
+	}
+}
+
+// expandedParent reports whether the closest known element above path has been encoded, or no element above path is
+// known at all
+func (enc *Encoder) expandedParent(path string) bool {
+	for i := strings.LastIndex(path, "/"); i >= 0; i = strings.LastIndex(path, "/") {
+		path = path[:i]
+		if expanded, ok := enc.known[path]; ok {
+			return expanded
+		}
+	}
+
+	return true
+}
+
+// hasDeclaredParam reports whether a param below prefix matches an element or attribute visited so far, or lies below
+// an element whose content has not been encoded yet. Other params are unknown, they can't start another occurrence of
+// the element at prefix.
+func (enc *Encoder) hasDeclaredParam(params map[string]interface{}, prefix string) bool {
+	for k := range params {
+		if k != prefix && !strings.HasPrefix(k, prefix+"/") {
+			continue
+		}
+
+		if _, ok := enc.known[k]; ok || !enc.expandedParent(k) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// dropEmpty records the attribute at path, which was submitted with an empty value, as left out
+func (enc *Encoder) dropEmpty(path []string) {
+	enc.unknown = append(enc.unknown, UnknownParam{Path: MakePath(path), Empty: true})
+}
+
+// UnknownErr : Returns all unknown params recorded so far as UnknownParamsError, or nil
+func (enc *Encoder) UnknownErr() error {
+	if len(enc.unknown) == 0 {
+		return nil
+	}
+
+	return enc.unknown
+}
+
+// closestPath returns the valid path with the smallest edit distance to path. Known paths are compared to just as
+// many segments of path, so a typo in a parent keeps the rest of path in the suggestion. Paths which differ in more
+// than a third of the characters of their last element are not offered, they are rather different params than typos.
+func (enc *Encoder) closestPath(path string) string {
+	segments := strings.Split(path, "/")
+
+	var closest string
+	var best int
+	for k := range enc.known {
+		n := strings.Count(k, "/") + 1
+		if n > len(segments) {
+			continue
+		}
+
+		head := MakePath(segments[:n])
+		d := levenshtein(head, k)
+		if d == 0 || d > maxTypos(k) {
+			continue
+		}
+
+		suggestion := MakePath(append([]string{k}, segments[n:]...))
+		if closest == "" || d < best || (d == best && suggestion < closest) {
+			closest, best = suggestion, d
+		}
+	}
+
+	return closest
+}
+
+// maxTypos returns the edit distance up to which a path is taken as a misspelling of the known path k. Two
+// characters are always allowed, since swapping two letters is the most common typo.
+func maxTypos(k string) int {
+	n := len(k[strings.LastIndex(k, "/")+1:]) / 3
+	if n < 2 {
+		n = 2
+	}
+
+	return n
+}
+
+// levenshtein returns the number of single character insertions, deletions and substitutions turning a into b
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+
+	return a
+}
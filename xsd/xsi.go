@@ -0,0 +1,230 @@
+package xsd
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/sezzle/sezzle-go-xml"
+)
+
+// XSINamespace is the namespace of the xsi:type attribute, which selects a type derived from the declared type of an
+// element, and the xsi:nil attribute
+const XSINamespace = "http://www.w3.org/2001/XMLSchema-instance"
+
+// xsiType is the name of the attribute param selecting the type of an element, like "Operations/@xsi:type"
+const xsiType = "xsi:type"
+
+type nilValue struct{}
+
+// Nil is submitted as the value of an element declared nillable to send it without content as xsi:nil="true", an
+// explicit null. Nil pointers in struct params are submitted as Nil.
+var Nil = nilValue{}
+
+// isNil reports whether the next occurrence of the element at key is submitted as Nil
+func isNil(params map[string]interface{}, key string) bool {
+	v, ok := params[key]
+	if val := reflect.ValueOf(v); ok && val.Kind() == reflect.Slice && val.Len() > 0 && !isScalar(val.Type()) {
+		v = val.Index(0).Interface()
+	}
+
+	_, ok = v.(nilValue)
+	return ok
+}
+
+// Typed is implemented by param structs of a type derived from the declared type of their element. XSIType returns
+// the name of that type, which is submitted as the param "element/@xsi:type".
+type Typed interface {
+	XSIType() string
+}
+
+// qName is a type name resolved to its namespace
+type qName struct {
+	space string
+	local string
+}
+
+func (n qName) String() string {
+	if n.space == "" {
+		return n.local
+	}
+
+	return "{" + n.space + "}" + n.local
+}
+
+// resolveQName resolves the qualified name name, like "tns:ItemType", by the aliases of ga
+func resolveQName(name string, ga GetAliaser) (qName, error) {
+	parts := splitQName(name)
+	if len(parts) != 2 {
+		return qName{}, fmt.Errorf("malformed type '%s'", name)
+	}
+
+	return qName{space: ga.GetAlias(parts[0]), local: parts[1]}, nil
+}
+
+// parseXSIType resolves the value of an xsi:type param. It is either "{namespace}Name", a qualified name by the
+// aliases of the schema of the element, or the bare name of a type in the namespace of the declared type.
+func parseXSIType(value string, declared qName, ga GetAliaser) (qName, error) {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "{") {
+		if i := strings.Index(value, "}"); i > 0 {
+			return qName{space: value[1:i], local: value[i+1:]}, nil
+		}
+	}
+
+	if i := strings.Index(value, ":"); i >= 0 {
+		space := ga.GetAlias(value[:i])
+		if space == "" {
+			return qName{}, invalidValue("type %q has an unknown prefix", value)
+		}
+		return qName{space: space, local: value[i+1:]}, nil
+	}
+
+	return qName{space: declared.space, local: value}, nil
+}
+
+// baseType returns the qualified name of the type the complex type is derived from, or "" if it is not derived
+func (c *ComplexType) baseType() string {
+	switch {
+	case c.Content != nil && c.Content.Restriction != nil:
+		return c.Content.Restriction.Base
+	case c.Content != nil:
+		return c.Content.Extension.Base
+	case c.SimpleContent != nil && c.SimpleContent.Restriction != nil:
+		return c.SimpleContent.Restriction.Base
+	case c.SimpleContent != nil && c.SimpleContent.Extension != nil:
+		return c.SimpleContent.Extension.Base
+	}
+
+	return ""
+}
+
+// lookupType returns the schema defining the type name, the complex type if it is one, and the qualified name of
+// the type it is derived from
+func lookupType(sr SchemaRepository, name qName) (Schemaer, *ComplexType, qName, error) {
+	schema, err := sr.GetSchema(name.space)
+	if err != nil {
+		return nil, nil, qName{}, err
+	}
+
+	if c, ga, err := schema.GetComplexType(name.local); err == nil {
+		var base qName
+		if c.baseType() != "" {
+			base, err = resolveQName(c.baseType(), ga)
+		}
+		return schema, c, base, err
+	}
+
+	s, ga, err := schema.GetSimpleType(name.local)
+	if err != nil {
+		return nil, nil, qName{}, err
+	}
+
+	var base qName
+	if s.List == nil && s.Union == nil && s.Restriction.SimpleType == nil {
+		base, err = resolveQName(s.Restriction.Base, ga)
+	}
+	return schema, nil, base, err
+}
+
+// isDerived reports whether the type name is derived from the type base, or is the type itself
+func isDerived(sr SchemaRepository, name, base qName) (bool, error) {
+	if base == (qName{space: schemaNamespace, local: "anyType"}) {
+		return true, nil
+	}
+
+	for name != base {
+		if name.space == schemaNamespace || name.local == "" {
+			return false, nil
+		}
+
+		var err error
+		_, _, name, err = lookupType(sr, name)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// derivedType takes the xsi:type submitted for the element at path, whose declared type is declared, and returns the
+// schema defining it and its qName. ok is false if no valid type was submitted. A type which is no derivation of the
+// declared one is reported as validation error, just like a missing one for an abstract declared type.
+func derivedType(enc *Encoder, sr SchemaRepository, ga GetAliaser, declared qName, params map[string]interface{}, path ...string) (schema Schemaer, name qName, ok bool, err error) {
+	attrPath := AttributePath(path, xsiType)
+	enc.expand(attrPath)
+
+	v, submitted := takeParam(params, MakePath(attrPath))
+	if !submitted {
+		if declared.local != "" {
+			if _, c, _, err := lookupType(sr, declared); err == nil && c != nil && c.Abstract {
+				enc.Invalid(path, "type '%s' of element '%s' is abstract, a derived type has to be submitted at '%s'",
+					declared, path[len(path)-1], MakePath(attrPath))
+			}
+		}
+		return nil, qName{}, false, nil
+	}
+	enc.consumed++
+
+	if declared.local == "" {
+		enc.Invalid(attrPath, "element '%s' has an anonymous type, which can't be replaced by xsi:type", path[len(path)-1])
+		return nil, qName{}, false, nil
+	}
+
+	name, err = parseXSIType(fmt.Sprint(v), declared, ga)
+	if enc.invalidValue(attrPath, err) {
+		return nil, qName{}, false, nil
+	}
+
+	schema, c, _, err := lookupType(sr, name)
+	if err != nil {
+		enc.Invalid(attrPath, "type '%s' is not defined by the schema", name)
+		return nil, qName{}, false, nil
+	}
+
+	derived, err := isDerived(sr, name, declared)
+	if err != nil {
+		return nil, qName{}, false, err
+	}
+
+	switch {
+	case !derived:
+		enc.Invalid(attrPath, "type '%s' is not derived from the type '%s' of element '%s'", name, declared, path[len(path)-1])
+		return nil, qName{}, false, nil
+	case c != nil && c.Abstract:
+		enc.Invalid(attrPath, "type '%s' is abstract", name)
+		return nil, qName{}, false, nil
+	}
+
+	return schema, name, true, nil
+}
+
+// xsiTypeAttrs returns the xsi:type attribute for the type name, preceded by the namespace declarations it needs.
+// The returned func ends the scope of the declarations.
+func xsiTypeAttrs(enc *Encoder, name qName) ([]xml.Attr, func()) {
+	xsiPrefix, attrs, endXSI := enc.declareNamespace(XSINamespace)
+
+	value := name.local
+	endType := func() {}
+	if name.space != "" {
+		var prefix string
+		var decl []xml.Attr
+		prefix, decl, endType = enc.declareNamespace(name.space)
+		attrs = append(attrs, decl...)
+		value = prefix + ":" + name.local
+	}
+
+	attrs = append(attrs, xml.Attr{Name: xml.Name{Prefix: xsiPrefix, Local: "type"}, Value: value})
+	return attrs, func() {
+		endType()
+		endXSI()
+	}
+}
+
+// xsiNilAttrs returns the xsi:nil attribute, preceded by the namespace declaration it needs. The returned func ends the
+// scope of the declaration.
+func xsiNilAttrs(enc *Encoder) ([]xml.Attr, func()) {
+	prefix, attrs, end := enc.declareNamespace(XSINamespace)
+	return append(attrs, xml.Attr{Name: xml.Name{Prefix: prefix, Local: "nil"}, Value: "true"}), end
+}